@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/url"
+	"strings"
+	"time"
+	"totp-util/qr"
+)
+
+// backupEntry holds the fields for one profile on a paper backup sheet
+type backupEntry struct {
+	Number      int
+	Name        string
+	Issuer      string
+	Account     string
+	Type        string
+	Secret      string
+	Fingerprint string
+	URI         string
+	QR          template.HTML
+}
+
+// backupTemplate is the paper backup sheet. Everything (styles, QR codes) is
+// inline, so the file works from a USB stick on a printer-attached machine
+// with no network. html/template takes care of escaping the profile fields.
+var backupTemplate = template.Must(template.New("backup").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>totp-util backup {{.Created}}</title>
+<style>
+body { font-family: sans-serif; margin: 12mm; color: #000; background: #fff; }
+h1 { font-size: 14pt; }
+.entry { display: flex; gap: 6mm; border: 1px solid #000; padding: 4mm;
+  margin-bottom: 6mm; break-inside: avoid; page-break-inside: avoid; }
+.entry svg { width: 45mm; height: 45mm; flex: none; }
+.entry table { border-collapse: collapse; font-size: 10pt; }
+.entry th { text-align: left; padding-right: 4mm; vertical-align: top; }
+.mono { font-family: monospace; font-size: 11pt; word-break: break-all; }
+</style>
+</head>
+<body>
+<h1>TOTP backup sheet, created {{.Created}}</h1>
+<p>Keep this page somewhere safe. Anyone who can see a QR code or secret on
+this page can generate login codes for that account.</p>
+{{range .Entries}}<div class="entry">
+{{.QR}}
+<table>
+<tr><th>#</th><td>{{.Number}} ({{.Name}})</td></tr>
+<tr><th>Issuer</th><td>{{.Issuer}}</td></tr>
+<tr><th>Account</th><td>{{.Account}}</td></tr>
+<tr><th>Type</th><td>{{.Type}}</td></tr>
+<tr><th>Secret</th><td class="mono">{{.Secret}}</td></tr>
+<tr><th>Fingerprint</th><td class="mono">{{.Fingerprint}}</td></tr>
+<tr><th>Created</th><td>{{$.Created}}</td></tr>
+<tr><th>URI</th><td class="mono">{{.URI}}</td></tr>
+</table>
+</div>
+{{end}}</body>
+</html>
+`))
+
+// BackupSheet renders the profiles into a self-contained, printable HTML page
+// with a QR code, issuer, account, grouped base32 secret, and fingerprint for
+// each one. If any profile fails validation, this returns an error rather
+// than making an incomplete backup.
+func BackupSheet(slots []Slot, created time.Time) (string, error) {
+	data := struct {
+		Created string
+		Entries []backupEntry
+	}{Created: created.UTC().Format("2006-01-02")}
+	for i, s := range slots {
+		p := s.Profile
+		uri, err := p.ToURI()
+		if err != nil {
+			return "", fmt.Errorf("Profile %v (%v): %v", i+1, s.Name,
+				strings.TrimSpace(err.Error()))
+		}
+		code, err := qr.Encode([]byte(uri))
+		if err != nil {
+			return "", fmt.Errorf("Profile %v (%v): %v", i+1, s.Name, err)
+		}
+		fingerprint, _ := p.Fingerprint()
+		kind := "TOTP"
+		if p.Type == "hotp" {
+			kind = "HOTP"
+		}
+		data.Entries = append(data.Entries, backupEntry{
+			Number:      i + 1,
+			Name:        s.Name,
+			Issuer:      p.Issuer,
+			Account:     p.Account,
+			Type:        kind,
+			Secret:      groupSecret(p.Secret),
+			Fingerprint: fingerprint,
+			URI:         uri,
+			QR:          template.HTML(qrSVG(code)),
+		})
+	}
+	var b strings.Builder
+	if err := backupTemplate.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// groupSecret cleans up a base32 secret the same way ToURI does, then splits
+// it into groups of four characters so it's easier to type back in by hand.
+func groupSecret(secret string) string {
+	if unesc, err := url.QueryUnescape(secret); err == nil {
+		secret = unesc
+	}
+	secret = strings.ToUpper(strings.TrimRight(secret, "="))
+	groups := []string{}
+	for len(secret) > 4 {
+		groups = append(groups, secret[:4])
+		secret = secret[4:]
+	}
+	return strings.Join(append(groups, secret), " ")
+}
+
+// qrSVG draws a QR code as an SVG image with dark modules on a white
+// background (the usual way around for paper, unlike ShowQR). Each row of
+// dark modules becomes runs of horizontal path segments to keep the file
+// small.
+func qrSVG(code *qr.Code) string {
+	const quiet = 4
+	size := code.Size + 2*quiet
+	var path strings.Builder
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if !code.Dark(x, y) {
+				continue
+			}
+			run := 1
+			for x+run < code.Size && code.Dark(x+run, y) {
+				run++
+			}
+			fmt.Fprintf(&path, "M%v %vh%vv1h-%vz", x+quiet, y+quiet, run, run)
+			x += run - 1
+		}
+	}
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" `+
+		`viewBox="0 0 %v %v" shape-rendering="crispEdges">`+
+		`<rect width="%v" height="%v" fill="#fff"/>`+
+		`<path d="%v" fill="#000"/></svg>`,
+		size, size, size, size, path.String())
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// Backup sheets should have an escaped entry for each profile
+func TestBackupSheet(t *testing.T) {
+	slots := []Slot{
+		{"a", Profile{Issuer: "A&B <Co>", Account: "alice",
+			Secret: "jbswy3dpehpk3pxpaa"}},
+		{"b", Profile{Type: "hotp", Account: "bob", Secret: key1}},
+	}
+	created := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	sheet, err := BackupSheet(slots, created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fingerprint, _ := slots[0].Profile.Fingerprint()
+	for _, want := range []string{
+		"created 2024-05-06",
+		"<td>A&amp;B &lt;Co&gt;</td>",
+		"\"mono\">JBSW Y3DP EHPK 3PXP AA</td>",
+		fingerprint,
+		"<td>HOTP</td>",
+		"\"mono\">GEZD GNBV GY3T QOJQ GEZD GNBV GY3T QOJQ</td>",
+		"otpauth://hotp/bob?secret=" + key1 + "&amp;counter=0",
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Error("\nwanted:", want, "\ngot:\n", sheet)
+		}
+	}
+	if n := strings.Count(sheet, "<svg"); n != 2 {
+		t.Error("\nwanted: 2 QR codes\ngot:", n)
+	}
+	// Invalid profiles should stop the backup rather than being left out
+	slots = append(slots, Slot{"c", Profile{Secret: key1, Digits: "5"}})
+	if _, err := BackupSheet(slots, created); err == nil ||
+		!strings.Contains(err.Error(), "Profile 3 (c)") {
+		t.Error("\nwanted: Profile 3 (c) error\ngot:", err)
+	}
+}
+
+// Secrets should be grouped in fours after the same cleanup as ToURI
+func TestGroupSecret(t *testing.T) {
+	cases := map[string]string{
+		"":                         "",
+		"abc":                      "ABC",
+		"JBSWY3DP":                 "JBSW Y3DP",
+		"jbswy3dpehpk3pxpaa%3D%3D": "JBSW Y3DP EHPK 3PXP AA",
+	}
+	for in, want := range cases {
+		if got := groupSecret(in); got != want {
+			t.Error("\ntried:", in, "\nwanted:", want, "\ngot:", got)
+		}
+	}
+}
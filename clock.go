@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// clockLayout is the format for showing times in the clock command output
+const clockLayout string = "2006-01-02 15:04:05 MST"
+
+// Clock is where a Session gets the time. Now returns the current time, and
+// Tick returns a channel that delivers a value once a second, for redrawing
+// codes and checking the idle timer. Tests use a fake clock so they don't
+// depend on the real time.
+type Clock interface {
+	Now() time.Time
+	Tick() <-chan time.Time
+}
+
+// systemClock is the real Clock, using the system time and a 1 second ticker
+type systemClock struct {
+	ticker *time.Ticker
+}
+
+func newSystemClock() *systemClock {
+	return &systemClock{ticker: time.NewTicker(time.Second)}
+}
+
+func (c *systemClock) Now() time.Time { return time.Now() }
+
+func (c *systemClock) Tick() <-chan time.Time { return c.ticker.C }
+
+// Stop stops the ticker
+func (c *systemClock) Stop() { c.ticker.Stop() }
+
+// ParseClockTimestamp parses a UTC timestamp in the MMDDhhmmCCYYss format used
+// by the QR code clock. That's almost the format for setting time with GNU
+// date (MMDDhhmmCCYY.ss), but without the "." so the QR code can be smaller.
+// The timestamp must be exactly 14 digits, and it must be a real calendar
+// date and time (no Feb 30, hour 24, etc).
+func ParseClockTimestamp(s string) (time.Time, error) {
+	if !regexp.MustCompile(`^[0-9]{14}$`).MatchString(s) {
+		return time.Time{}, errors.New(
+			"Timestamp should be 14 digits in MMDDhhmmCCYYss format")
+	}
+	// Go's time.Parse checks the ranges of all the fields, including days
+	// of the month for the given month and year
+	t, err := time.Parse("01021504200605", s)
+	if err != nil {
+		return time.Time{}, errors.New("Timestamp is not a valid UTC time: " +
+			err.Error())
+	}
+	return t, nil
+}
+
+// ClockOffset works out the offset to add to now so that it will be in sync
+// with the UTC timestamp s from the QR code clock. The QR code clock only has
+// a resolution of one second, so the offset gets rounded to whole seconds. An
+// empty timestamp means no offset.
+func ClockOffset(s string, now time.Time) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	t, err := ParseClockTimestamp(s)
+	if err != nil {
+		return 0, err
+	}
+	return t.Sub(now).Round(time.Second), nil
+}
+
+// ClockCommand sets the system clock from a QR code clock timestamp. This is
+// a replacement for clock/set_clock.py that does stricter input validation and
+// uses the settimeofday syscall rather than shelling out to `sudo date`. It
+// reads one timestamp line from in, shows how far off the system clock is,
+// and asks for confirmation before setting the clock. With dryRun set, it
+// goes through all the same steps except for actually setting the clock.
+func ClockCommand(in io.Reader, out io.Writer, dryRun bool) error {
+	scanner := bufio.NewScanner(in)
+	fmt.Fprintf(out, "Scan QR clock> ")
+	if !scanner.Scan() {
+		return errors.New("No timestamp")
+	}
+	// Note when the timestamp arrived so the time spent waiting for the
+	// confirmation can be added back before setting the clock
+	scannedAt := time.Now()
+	// Be strict about junk, but tolerate a CR from scanners that send CRLF
+	qrTime, err := ParseClockTimestamp(strings.TrimSuffix(scanner.Text(), "\r"))
+	if err != nil {
+		return err
+	}
+	delta := qrTime.Sub(scannedAt).Round(time.Second)
+	fmt.Fprintf(out, "QR clock time: %v\n", qrTime.Format(clockLayout))
+	fmt.Fprintf(out, "System time:   %v\n",
+		scannedAt.UTC().Format(clockLayout))
+	fmt.Fprintf(out, "Delta:         %+v\n", delta)
+	fmt.Fprintf(out, "Set system clock? [y/N] ")
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != "y" {
+		fmt.Fprintln(out, "System clock not changed")
+		return nil
+	}
+	newTime := qrTime.Add(time.Since(scannedAt))
+	if dryRun {
+		fmt.Fprintf(out, "Dry run: would set system clock to %v\n",
+			newTime.UTC().Format(clockLayout))
+		return nil
+	}
+	if err := setSystemClock(newTime); err != nil {
+		return fmt.Errorf("Unable to set system clock (try sudo?): %v", err)
+	}
+	fmt.Fprintf(out, "System clock set to %v\n",
+		time.Now().UTC().Format(clockLayout))
+	return nil
+}
@@ -0,0 +1,13 @@
+package main
+
+import (
+	"syscall"
+	"time"
+)
+
+// setSystemClock sets the system time with the settimeofday syscall. This
+// needs root (or CAP_SYS_TIME).
+func setSystemClock(t time.Time) error {
+	tv := syscall.NsecToTimeval(t.UnixNano())
+	return syscall.Settimeofday(&tv)
+}
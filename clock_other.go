@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// setSystemClock is only implemented for Linux
+func setSystemClock(t time.Time) error {
+	return errors.New("setting the system clock is only supported on Linux")
+}
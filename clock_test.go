@@ -0,0 +1,99 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// Well formed timestamps should parse as UTC
+func Test_clock_timestamp_valid(t *testing.T) {
+	cases := map[string]time.Time{
+		"01011200202601": time.Date(2026, 1, 1, 12, 0, 1, 0, time.UTC),
+		"12312359199959": time.Date(1999, 12, 31, 23, 59, 59, 0, time.UTC),
+		"02290000202400": time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+	}
+	for s, want := range cases {
+		got, err := ParseClockTimestamp(s)
+		if err != nil {
+			t.Error("\ntried:", s, "\ngot:", err)
+		}
+		if !got.Equal(want) {
+			t.Error("\ntried:", s, "\nwanted:", want, "\ngot:", got)
+		}
+	}
+}
+
+// Malformed timestamps and impossible calendar dates should fail
+func Test_clock_timestamp_invalid(t *testing.T) {
+	for _, s := range []string{
+		"", "0101120020260", "010112002026011", "01011200202601\n",
+		"0101120020260x", " 01011200202601", "01011200202601 junk",
+		"13011200202601", "00011200202601", "01321200202601",
+		"02291200202501", "02301200202401", "01012400202601",
+		"01011260202601", "01011200202660",
+	} {
+		if got, err := ParseClockTimestamp(s); err == nil {
+			t.Error("\ntried:", s, "\nwanted: error \ngot:", got)
+		}
+	}
+}
+
+// The clock offset should bring now in sync with the timestamp, in whole
+// seconds, and blank should mean no offset
+func Test_clock_offset(t *testing.T) {
+	now := time.Date(2029, 12, 31, 12, 0, 0, 700*int(time.Millisecond), time.UTC)
+	offset, err := ClockOffset("01011200203001", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2030, 1, 1, 12, 0, 1, 0, time.UTC)
+	if d := now.Add(offset).Sub(want); d < -time.Second || d > time.Second {
+		t.Error("\nwanted:", want, "\ngot:", now.Add(offset), "\noffset:", offset)
+	}
+	if offset%time.Second != 0 {
+		t.Error("\nwanted: whole seconds \ngot:", offset)
+	}
+	if _, err := ClockOffset("99999999999999", now); err == nil {
+		t.Error("\nwanted: error \ngot: nil")
+	}
+	if offset, err := ClockOffset("", now); err != nil || offset != 0 {
+		t.Error("\nwanted: 0 \ngot:", offset, err)
+	}
+}
+
+// Clock command dry run should validate, confirm, and not touch the clock
+func Test_clock_command_dry_run(t *testing.T) {
+	var out strings.Builder
+	in := strings.NewReader("01011200203001\ny\n")
+	if err := ClockCommand(in, &out, true); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"QR clock time: 2030-01-01 12:00:01 UTC",
+		"Dry run: would set system clock to 2030-01-01 12:00:01 UTC",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Error("\nwanted:", want, "\ngot:", out.String())
+		}
+	}
+}
+
+// Clock command should stop without confirmation or with a bad timestamp
+func Test_clock_command_rejects(t *testing.T) {
+	var out strings.Builder
+	in := strings.NewReader("01011200203001\nn\n")
+	if err := ClockCommand(in, &out, true); err != nil {
+		t.Error(err)
+	}
+	if !strings.Contains(out.String(), "System clock not changed") {
+		t.Error("\nwanted: System clock not changed \ngot:", out.String())
+	}
+	for _, input := range []string{"", "01011200203001junk\ny\n", "now\ny\n"} {
+		out.Reset()
+		err := ClockCommand(strings.NewReader(input), &out, true)
+		if err == nil || strings.Contains(out.String(), "Dry run") {
+			t.Error("\ntried:", input, "\nwanted: error \ngot:", out.String())
+		}
+	}
+}
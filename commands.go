@@ -0,0 +1,397 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"totp-util/shamir"
+)
+
+// Command is one entry in the command registry. The menu, help, and input
+// dispatch all come from the registry, so adding a command only means adding
+// an entry to commands.
+type Command struct {
+	// Syntax is how the command looks in the menu. For named commands, it
+	// starts with the name, then has "=" and a placeholder if the command
+	// takes a value (like "sel=<n>"), or " " and a placeholder if it takes an
+	// optional word (like "help <cmd>").
+	Syntax string
+	// Help is the one line description for the menu
+	Help string
+	// Detail is the longer description for "help <cmd>"
+	Detail string
+	// Parse recognizes commands that don't start with a name, like scanned
+	// URIs, and returns the argument for Run. Named commands leave it nil,
+	// and their argument is whatever comes after the "=" or " ".
+	Parse func(line string) (arg string, ok bool)
+	// Run carries out the command
+	Run func(s *Session, arg string)
+	// Secret marks commands whose input includes a secret, so the line
+	// editor keeps them out of its history
+	Secret bool
+	// NoAbbrev marks commands that throw away profiles, which only run when
+	// the whole name gets typed, so a short typo can't set them off
+	NoAbbrev bool
+}
+
+// Name returns the name used to look up the command. For commands with a
+// Parse function, the name only matters for "help <cmd>".
+func (c Command) Name() string {
+	if c.Parse != nil {
+		return strings.Trim(c.Syntax, "<>.")
+	}
+	if i := strings.IndexAny(c.Syntax, "= "); i >= 0 {
+		return c.Syntax[:i]
+	}
+	return c.Syntax
+}
+
+// separator returns "=" or " " for named commands that take an argument, or
+// "" for commands that don't
+func (c Command) separator() string {
+	if i := strings.IndexAny(c.Syntax, "= "); i >= 0 && c.Parse == nil {
+		return c.Syntax[i : i+1]
+	}
+	return ""
+}
+
+// prefixParser makes a Parse function for commands that start with a fixed
+// prefix. The whole line is the argument.
+func prefixParser(prefix string) func(string) (string, bool) {
+	return func(line string) (string, bool) {
+		return line, strings.HasPrefix(line, prefix)
+	}
+}
+
+// timestampRE matches QR code clock timestamps (MMDDhhmmCCYYss)
+var timestampRE = regexp.MustCompile(`^[0-9]{14}$`)
+
+// commands is the command registry, in menu order
+var commands = []Command{
+	{Syntax: "?", Help: "Show menu",
+		Detail: "Use help <cmd> for more about a command.",
+		Run:    func(s *Session, _ string) { s.ShowMenu() }},
+	{Syntax: "help <cmd>", Help: "Show details for command <cmd>",
+		Detail: "Commands can be shortened to any prefix that only fits one\n" +
+			"command, like \"rev\" for \"reveal\" or \"sec=<s>\" for \"secret=<s>\".",
+		Run: func(s *Session, arg string) { s.ShowHelp(arg) }},
+	{Syntax: "p", Help: "Print profile (with secret masked, unless masking is off)",
+		Detail: "The printout includes the fingerprint, which is a short hash of\n" +
+			"the decoded secret and code parameters for comparing backups.",
+		Run: func(s *Session, _ string) { s.PrintProfile(s.CurrentProfile()) }},
+	{Syntax: "reveal", Help: "Print profile with secret shown",
+		Detail: "This shows the secret once, without changing the masking setting.",
+		Run:    func(s *Session, _ string) { s.RevealProfile() }},
+	{Syntax: "mask=<s>",
+		Help: "Set secret masking for printouts to <s> (\"on\" or \"off\")",
+		Detail: "Masking shows only the first and last few characters of the\n" +
+			"secret. It's on at startup.",
+		Run: func(s *Session, arg string) { s.SetMasking(arg) }},
+	{Syntax: "u", Help: "Print profile as cleaned up otpauth:// URI",
+		Detail: "The URI only includes parameters that are set, in a standard\n" +
+			"order, with the secret in uppercase and without padding.",
+		Run: func(s *Session, _ string) { s.PrintURI(s.CurrentProfile()) }},
+	{Syntax: "qr", Help: "Show cleaned up profile URI as a QR code",
+		Detail: "The QR code is drawn with Unicode block characters for light text\n" +
+			"on a dark background.",
+		Run: func(s *Session, _ string) { s.ShowQR(s.CurrentProfile()) }},
+	{Syntax: "lint", Help: "Check scanned URI for problems",
+		Detail: "Lint is stricter than the URI parser. It reports things like\n" +
+			"unknown parameters, mismatched issuers, and weak secrets.",
+		Run: func(s *Session, _ string) { s.LintProfile(s.CurrentProfile()) }},
+	{Syntax: "cmp",
+		Help: "Compare next scanned URI against profile (secrets stay hidden)",
+		Detail: "After cmp, scan a URI (from a backup, for example). The fields\n" +
+			"get compared one by one, and the secrets are compared without\n" +
+			"showing either of them.",
+		Run: func(s *Session, _ string) { s.CompareURI() }},
+	{Syntax: "otpauth://...",
+		Help: "Parse TOTP or HOTP QR Code URI into new profile",
+		Detail: "Scanning a TOTP URI starts showing codes right away. Scanning an\n" +
+			"HOTP URI shows the code for its counter.",
+		Parse:  prefixParser("otpauth://"),
+		Secret: true,
+		Run:    func(s *Session, arg string) { s.ScanURI(arg) }},
+	{Syntax: "otpauth-mi...",
+		Help: "Decode Google Authenticator export QR Code URI",
+		Detail: "Big exports span several QR codes. Scan them all (in any order),\n" +
+			"then use m=<n> to load accounts.",
+		Parse:  prefixParser("otpauth-migration://"),
+		Secret: true,
+		Run:    func(s *Session, arg string) { s.ParseMigrationURI(arg) }},
+	{Syntax: "m", Help: "List accounts from Google Authenticator export",
+		Detail: "Secrets aren't shown. The list lasts until the idle timer goes off\n" +
+			"or a QR code from a different export gets scanned.",
+		Run: func(s *Session, _ string) { s.ShowMigrationBatch() }},
+	{Syntax: "m=<n>", Help: "Load account <n> from Google Authenticator export",
+		Detail: "The account gets added to the end of the profile list.",
+		Run:    func(s *Session, arg string) { s.LoadMigrationProfile(arg) }},
+	{Syntax: "secret", Help: "Enter secret with echo off (confirm by retyping or fingerprint)",
+		Detail: "This keeps the secret out of the terminal scrollback. Spaces are\n" +
+			"ignored, so secrets can be typed in groups like on a backup sheet.\n" +
+			"To confirm, type the secret again, or press Enter to see the\n" +
+			"fingerprint and compare it. If there are no profiles yet, this\n" +
+			"adds an empty one first.",
+		Run: func(s *Session, _ string) { s.EnterSecret() }},
+	{Syntax: "secret=<s>", Help: "Set secret to <s> (must be base32 string)",
+		Detail: "The secret shows on screen as you type it. Use the secret command\n" +
+			"to enter it with echo off instead. If there are no profiles yet,\n" +
+			"this adds an empty one first.",
+		Secret: true,
+		Run:    func(s *Session, arg string) { s.EditProfile().Secret = arg }},
+	{Syntax: "algorithm=<s>",
+		Help: "Set algorithm to <s> (can be empty, \"SHA1\", \"SHA256\", or \"SHA512\")",
+		Detail: "Empty means the default, SHA1. Many apps ignore this parameter\n" +
+			"and always use SHA1.",
+		Run: func(s *Session, arg string) { s.EditProfile().Algorithm = arg }},
+	{Syntax: "digits=<s>", Help: "Set digits to <s> (can be empty or 6..10)",
+		Detail: "Empty means the default, 6 digits.",
+		Run:    func(s *Session, arg string) { s.EditProfile().Digits = arg }},
+	{Syntax: "period=<s>",
+		Help:   "Set period to <s> (can be empty or 1..3600 seconds)",
+		Detail: "Empty means the default, 30 seconds.",
+		Run:    func(s *Session, arg string) { s.EditProfile().Period = arg }},
+	{Syntax: "counter=<s>",
+		Help:   "Set HOTP counter to <s> (can be empty or an integer)",
+		Detail: "Empty means 0. The counter only matters for HOTP profiles.",
+		Run:    func(s *Session, arg string) { s.EditProfile().Counter = arg }},
+	{Syntax: "clock=<s>",
+		Help: "Set clock offset from UTC time <s> (MMDDhhmmCCYYss or empty)",
+		Detail: "The offset makes up for a wrong workstation clock without\n" +
+			"changing the system clock. Empty clears the offset.",
+		Run: func(s *Session, arg string) { s.SetClock(arg) }},
+	{Syntax: "<timestamp>",
+		Help:   "Set clock offset from scanned QR clock timestamp",
+		Detail: "This is the same as clock=<s>, for scanning clock/index.html.",
+		Parse: func(line string) (string, bool) {
+			return line, timestampRE.MatchString(line)
+		},
+		Run: func(s *Session, arg string) { s.SetClock(arg) }},
+	{Syntax: "ls", Help: "List profiles",
+		Detail: "The current profile is marked with \"*\". Secrets aren't shown.",
+		Run:    func(s *Session, _ string) { s.ListProfiles() }},
+	{Syntax: "sel=<n>", Help: "Select profile <n>",
+		Detail: "Profile numbers are the ones shown by ls.",
+		Run:    func(s *Session, arg string) { s.SelectProfile(arg) }},
+	{Syntax: "name=<s>", Help: "Rename current profile to <s>",
+		Detail: "Names start out as the issuer (or account) from the URI.",
+		Run:    func(s *Session, arg string) { s.RenameProfile(arg) }},
+	{Syntax: "dup", Help: "Duplicate current profile",
+		Detail: "The copy becomes the current profile, which is handy for trying\n" +
+			"out edits without losing the original.",
+		Run: func(s *Session, _ string) { s.DuplicateProfile() }},
+	{Syntax: "del", Help: "Delete current profile",
+		Detail: "The next profile in the list becomes the current profile. This\n" +
+			"can't be abbreviated.",
+		NoAbbrev: true,
+		Run:      func(s *Session, _ string) { s.DeleteProfile() }},
+	{Syntax: "clr", Help: "Clear all profiles",
+		Detail: "There's no undo, so save a vault first if you need the profiles.\n" +
+			"This can't be abbreviated.",
+		NoAbbrev: true,
+		Run:      func(s *Session, _ string) { s.ClearProfiles() }},
+	{Syntax: "save=<path>",
+		Help: "Save profiles to passphrase encrypted vault file <path>",
+		Detail: "This asks for the passphrase twice, with echo off. The vault uses\n" +
+			"scrypt and AES-256-GCM. This is the only command that writes\n" +
+			"profiles to disk. It won't overwrite an existing file.",
+		Run: func(s *Session, arg string) { s.SaveVault(arg) }},
+	{Syntax: "load=<path>",
+		Help:   "Add profiles from passphrase encrypted vault file <path>",
+		Detail: "Loaded profiles go after the ones that are already in the list.",
+		Run:    func(s *Session, arg string) { s.LoadVault(arg) }},
+	{Syntax: "backup=<path>",
+		Help: "Write printable HTML backup sheet of profiles to <path>",
+		Detail: "The sheet has a QR code and the secret for each profile, so\n" +
+			"delete it after printing. This won't overwrite an existing file.",
+		Run: func(s *Session, arg string) { s.WriteBackup(arg) }},
+	{Syntax: "split=<k>,<n>",
+		Help: "Split profile URI into <n> shares so any <k> can restore it",
+		Detail: "Each share gets printed as text and as a QR code. Fewer than <k>\n" +
+			"shares reveal nothing about the profile.",
+		Run: func(s *Session, arg string) { s.SplitProfile(arg) }},
+	{Syntax: "TOTPSHARE1...",
+		Help:   "Add scanned share (restores profile once there are enough)",
+		Detail: "Shares can be scanned or typed in any order.",
+		Parse: func(line string) (string, bool) {
+			return line, shamir.IsShare(line)
+		},
+		Secret: true,
+		Run:    func(s *Session, arg string) { s.AddShare(arg) }},
+	{Syntax: "t", Help: "Show updating TOTP code (press Enter key to stop)",
+		Detail: "The countdown shows how many seconds the code has left. Use\n" +
+			"clock=<s> first if the workstation clock is off.",
+		Run: func(s *Session, _ string) { s.ShowTotp(s.CurrentProfile()) }},
+	{Syntax: "dash",
+		Help: "Show updating codes for all profiles (press Enter key to stop)",
+		Detail: "The dashboard shows the current and next codes, with a countdown\n" +
+			"bar for TOTP profiles.",
+		Run: func(s *Session, _ string) { s.ShowDashboard() }},
+	{Syntax: "verify=<s>",
+		Help: "Check TOTP code <s> against profile (allows ±1 step)",
+		Detail: "This is for checking codes from another device. Spaces in the\n" +
+			"code are ignored.",
+		Run: func(s *Session, arg string) {
+			s.VerifyTotp(s.CurrentProfile(), arg)
+		}},
+	{Syntax: "h", Help: "Show HOTP code for current counter",
+		Detail: "This doesn't change the counter. See h+.",
+		Run:    func(s *Session, _ string) { s.ShowHotp(s.CurrentProfile()) }},
+	{Syntax: "h+", Help: "Advance HOTP counter and show code",
+		Detail: "Use this to keep the profile in step with a token or validator\n" +
+			"that has moved on to the next counter.",
+		Run: func(s *Session, _ string) { s.AdvanceHotp() }},
+	{Syntax: "idle=<n>",
+		Help: "Clear everything after <n> minutes without input (0 = never)",
+		Detail: "The idle timer clears all the profiles and the screen, in case\n" +
+			"someone walked away. It's 10 minutes at startup.",
+		Run: func(s *Session, arg string) { s.SetIdleTimeout(arg) }},
+	{Syntax: "q", Help: "Quit",
+		Detail: "All the profiles get cleared from RAM on the way out. This can't\n" +
+			"be abbreviated.",
+		NoAbbrev: true,
+		Run:      func(s *Session, _ string) { s.quit = true }},
+}
+
+// FindCommand works out which command a line of input is for, and what its
+// argument is. Commands with a Parse function get the first try. Otherwise,
+// the name at the start of the line can be any prefix of a command name, as
+// long as it only fits one name. Commands marked NoAbbrev need the whole name.
+func (s *Session) FindCommand(line string) (Command, string, error) {
+	return findCommand(s.commands, line)
+}
+
+// findCommand does the work for FindCommand with the commands in cmds
+func findCommand(cmds []Command, line string) (Command, string, error) {
+	for _, c := range cmds {
+		if c.Parse == nil {
+			continue
+		}
+		if arg, ok := c.Parse(line); ok {
+			return c, arg, nil
+		}
+	}
+	name, sep, arg := line, "", ""
+	if i := strings.IndexAny(line, "= "); i >= 0 {
+		name, sep, arg = line[:i], line[i:i+1], line[i+1:]
+	}
+	forms, err := lookupCommand(cmds, name, false)
+	if err != nil {
+		return Command{}, "", err
+	}
+	if c := forms[0]; c.NoAbbrev && c.Name() != name {
+		return Command{}, "", fmt.Errorf(
+			"Type \"%v\" in full (it can't be abbreviated).", c.Name())
+	}
+	usage := []string{}
+	for _, c := range forms {
+		// Words after a space are optional
+		if c.separator() == sep || (c.separator() == " " && sep == "") {
+			return c, arg, nil
+		}
+		usage = append(usage, c.Syntax)
+	}
+	return Command{}, "", fmt.Errorf("Usage: %v", strings.Join(usage, " or "))
+}
+
+// errUnrecognized means no command fits the input
+var errUnrecognized = errors.New("Unrecognized input. Try '?' to show menu.")
+
+// lookupCommand returns the commands called name (like "m" and "m=<n>"), or
+// called something that starts with name, if that only fits one name. Commands
+// with a Parse function only count if withParse is true.
+func lookupCommand(cmds []Command, name string, withParse bool) (
+	[]Command, error) {
+	if name == "" {
+		return nil, errUnrecognized
+	}
+	exact := []Command{}
+	prefixed := []Command{}
+	names := []string{}
+	for _, c := range cmds {
+		if c.Parse != nil && !withParse {
+			continue
+		}
+		switch {
+		case c.Name() == name:
+			exact = append(exact, c)
+		case strings.HasPrefix(c.Name(), name):
+			if !slices.Contains(names, c.Name()) {
+				names = append(names, c.Name())
+			}
+			prefixed = append(prefixed, c)
+		}
+	}
+	switch {
+	case len(exact) > 0:
+		return exact, nil
+	case len(names) == 0:
+		return nil, errUnrecognized
+	case len(names) > 1:
+		return nil, fmt.Errorf("Ambiguous command \"%v\" (could be %v).", name,
+			strings.Join(names, ", "))
+	}
+	return prefixed, nil
+}
+
+// keepInHistory reports whether a line of input can go in the line editor
+// history. Lines for commands marked Secret are left out, and so are lines
+// that aren't commands at all, in case one is a mistyped secret=<s>.
+func keepInHistory(line string) bool {
+	c, _, err := findCommand(commands, line)
+	return err == nil && !c.Secret
+}
+
+// ShowMenu prints the syntax and help for each command
+func (s *Session) ShowMenu() {
+	for _, c := range s.commands {
+		s.printMenuLine(c)
+	}
+}
+
+// printMenuLine prints the syntax and help for a command, padding the syntax
+// to the width of the longest one so the menu lines up in columns
+func (s *Session) printMenuLine(c Command) {
+	width := 0
+	for _, c := range s.commands {
+		width = max(width, len(c.Syntax))
+	}
+	fmt.Fprintf(s.out, " %-*v - %v\n", width, c.Syntax, c.Help)
+}
+
+// ShowHelp prints the menu lines and details for the command called name (or
+// an abbreviation of it). Without a name, it shows the menu.
+func (s *Session) ShowHelp(name string) {
+	if name == "" {
+		s.ShowMenu()
+		return
+	}
+	// Allow for names copied from the menu, like "sel=<n>" or "<timestamp>"
+	if i := strings.IndexAny(name, "= "); i >= 0 {
+		name = name[:i]
+	}
+	if n := strings.Trim(name, "<>."); n != "" {
+		name = n
+	}
+	forms, err := lookupCommand(s.commands, name, true)
+	if err == errUnrecognized {
+		fmt.Fprintf(s.out, "No command called \"%v\". Try '?' to show menu.\n",
+			name)
+		return
+	} else if err != nil {
+		fmt.Fprintln(s.out, err)
+		return
+	}
+	for _, c := range forms {
+		s.printMenuLine(c)
+	}
+	for _, c := range forms {
+		for _, line := range strings.Split(c.Detail, "\n") {
+			if line != "" {
+				fmt.Fprintf(s.out, "   %v\n", line)
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// Every registry entry should be complete, and named commands should have
+// distinct syntax
+func TestCommandRegistry(t *testing.T) {
+	seen := map[string]bool{}
+	for _, c := range commands {
+		if c.Syntax == "" || c.Help == "" || c.Detail == "" || c.Run == nil {
+			t.Error("\nincomplete command:", c.Syntax)
+		}
+		if seen[c.Syntax] {
+			t.Error("\nrepeated syntax:", c.Syntax)
+		}
+		seen[c.Syntax] = true
+	}
+}
+
+// Input lines should find the right command and argument, including
+// abbreviations, and report ambiguous or malformed commands
+func TestFindCommand(t *testing.T) {
+	s := NewSession(strings.NewReader(""), &strings.Builder{}, newFakeClock())
+	cases := []struct {
+		Line, Syntax, Arg, Err string
+	}{
+		{"ls", "ls", "", ""},
+		{"m", "m", "", ""},
+		{"m=2", "m=<n>", "2", ""},
+		{"ma=off", "mask=<s>", "off", ""},
+		{"rev", "reveal", "", ""},
+		{"sec=ABC=", "secret=<s>", "ABC=", ""},
+		{"sec", "secret", "", ""},
+		{"secret", "secret", "", ""},
+		{"name=", "name=<s>", "", ""},
+		{"verify=123 456", "verify=<s>", "123 456", ""},
+		{"h", "h", "", ""},
+		{"h+", "h+", "", ""},
+		{"he", "help <cmd>", "", ""},
+		{"help sel", "help <cmd>", "sel", ""},
+		{"otpauth://totp/x?secret=A", "otpauth://...", "otpauth://totp/x?secret=A", ""},
+		{"otpauth-migration://offline?data=", "otpauth-mi...",
+			"otpauth-migration://offline?data=", ""},
+		{"01011200203001", "<timestamp>", "01011200203001", ""},
+		{"totpshare1-x", "TOTPSHARE1...", "totpshare1-x", ""},
+		{"d", "", "", `Ambiguous command "d" (could be digits, dup, del, dash).`},
+		{"s=1", "", "", `Ambiguous command "s" (could be secret, sel, save, split).`},
+		{"dig", "", "", "Usage: digits=<s>"},
+		{"del", "del", "", ""},
+		{"de", "", "", `Type "del" in full (it can't be abbreviated).`},
+		{"du", "dup", "", ""},
+		{"clr", "clr", "", ""},
+		{"cl", "", "", `Ambiguous command "cl" (could be clock, clr).`},
+		{"clr=1", "", "", "Usage: clr"},
+		{"q", "q", "", ""},
+		{"ls=1", "", "", "Usage: ls"},
+		{"bogus", "", "", "Unrecognized input. Try '?' to show menu."},
+		{"timestamp", "", "", "Unrecognized input. Try '?' to show menu."},
+		{"=x", "", "", "Unrecognized input. Try '?' to show menu."},
+	}
+	for _, c := range cases {
+		cmd, arg, err := s.FindCommand(c.Line)
+		errText := ""
+		if err != nil {
+			errText = err.Error()
+		}
+		if cmd.Syntax != c.Syntax || arg != c.Arg || errText != c.Err {
+			t.Error("\ntried:", c.Line, "\nwanted:", c.Syntax, c.Arg, c.Err,
+				"\ngot:", cmd.Syntax, arg, errText)
+		}
+	}
+}
+
+// The menu should line up, and help should show the details for each form of
+// a command
+func TestShowHelp(t *testing.T) {
+	got := runSession("help m", "help <timestamp>", "help sel=<n>", "help zz",
+		"help otp", "help")
+	for _, want := range []string{
+		" m             - List accounts from Google Authenticator export\n" +
+			" m=<n>         - Load account <n> from Google Authenticator export\n" +
+			"   Secrets aren't shown.",
+		"   The account gets added to the end of the profile list.\n",
+		" <timestamp>   - Set clock offset from scanned QR clock timestamp\n" +
+			"   This is the same as clock=<s>",
+		" sel=<n>       - Select profile <n>\n   Profile numbers",
+		`No command called "zz". Try '?' to show menu.`,
+		`Ambiguous command "otp" (could be otpauth://, otpauth-mi).`,
+		">  ?             - Show menu\n help <cmd>    - Show details",
+	} {
+		if !strings.Contains(got, want) {
+			t.Error("\nwanted:", want, "\ngot:\n", got)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// dashBarWidth is how many characters wide the dashboard countdown bars are
+const dashBarWidth int = 20
+
+// DashboardText renders one frame of the dashboard view for the given Unix
+// time. Each profile gets a row with issuer, account, current code, next code,
+// and a countdown bar. HOTP profiles don't count down, so their rows show the
+// codes for the current and next counter values instead. Profiles that fail
+// validation get a row with an error note rather than stopping the whole view.
+func DashboardText(slots []Slot, unixTime int64) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%v UTC (press Enter key to stop)\n\n",
+		time.Unix(unixTime, 0).UTC().Format("2006-01-02 15:04:05"))
+	if len(slots) == 0 {
+		b.WriteString("No profiles loaded. Try scanning a QR code.\n")
+		return b.String()
+	}
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, " #\tIssuer\tAccount\tCode\tNext\tTime")
+	for i, s := range slots {
+		p := s.Profile
+		fmt.Fprintf(w, "%2d\t%v\t%v\t%v\n", i+1, p.Issuer, p.Account,
+			dashboardCodes(p, unixTime))
+	}
+	w.Flush()
+	return b.String()
+}
+
+// dashboardCodes returns the code, next code, and countdown columns for one
+// dashboard row
+func dashboardCodes(p Profile, unixTime int64) string {
+	if p.Type == "hotp" {
+		h, err := NewHotp(p.Secret, p.Digits, p.Algorithm, p.Counter)
+		if err != nil {
+			return "(unsupported parameter value)"
+		}
+		defer h.Secret.Wipe()
+		code, err := h.Code()
+		if err != nil {
+			return "(" + err.Error() + ")"
+		}
+		counter := h.Counter
+		next := "-"
+		if counter < math.MaxUint64 {
+			h.Counter++
+			next, _ = h.Code()
+		}
+		return fmt.Sprintf("%v\t%v\tcounter %v", code, next, counter)
+	}
+	t, err := NewTotp(p.Secret, p.Digits, p.Algorithm, p.Period)
+	if err != nil {
+		return "(unsupported parameter value)"
+	}
+	defer t.Secret.Wipe()
+	code, validSeconds, err := t.CodeAtTime(unixTime)
+	if err != nil {
+		return "(" + err.Error() + ")"
+	}
+	next, _, _ := t.CodeAtTime(unixTime + int64(t.Period))
+	return fmt.Sprintf("%v\t%v\t%v %vs", code, next,
+		countdownBar(validSeconds, t.Period, dashBarWidth), validSeconds)
+}
+
+// countdownBar draws a bar that shrinks as the remaining seconds of a TOTP
+// period tick down. Partly used cells round up so the bar only empties out
+// when the period is over.
+func countdownBar(validSeconds, period, width int) string {
+	full := (validSeconds*width + period - 1) / period
+	if full > width {
+		full = width
+	}
+	return strings.Repeat("█", full) + strings.Repeat("░", width-full)
+}
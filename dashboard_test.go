@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// Dashboard rows should show current and next codes for TOTP and HOTP
+func TestDashboardText(t *testing.T) {
+	slots := []Slot{
+		{"a", Profile{Issuer: "Example", Account: "alice", Secret: key1,
+			Digits: "8"}},
+		{"b", Profile{Type: "hotp", Issuer: "Ex", Account: "bob",
+			Secret: key1, Counter: "8"}},
+		{"c", Profile{Issuer: "Bad", Secret: key1, Digits: "5"}},
+	}
+	// RFC6238 Appendix B: 1111111109 is the last second of its step, and
+	// 1111111111 is in the next step
+	got := DashboardText(slots, 1111111109)
+	want := []string{
+		"2005-03-18 01:58:29 UTC",
+		" 1  Example  alice    07081804  14050471  █░░░░░░░░░░░░░░░░░░░ 1s",
+		" 2  Ex       bob      399871    520489    counter 8",
+		" 3  Bad               (unsupported parameter value)",
+	}
+	for _, w := range want {
+		if !strings.Contains(got, w) {
+			t.Error("\nwanted:", w, "\ngot:\n", got)
+		}
+	}
+}
+
+// Countdown bars should start full and only empty out when time is up
+func TestCountdownBar(t *testing.T) {
+	cases := []struct {
+		Seconds, Period, Width int
+		Want                   string
+	}{
+		{30, 30, 5, "█████"},
+		{29, 30, 5, "█████"},
+		{24, 30, 5, "████░"},
+		{1, 30, 5, "█░░░░"},
+		{1, 1, 5, "█████"},
+	}
+	for _, c := range cases {
+		if got := countdownBar(c.Seconds, c.Period, c.Width); got != c.Want {
+			t.Error("\ntried:", c, "\nwanted:", c.Want, "\ngot:", got)
+		}
+	}
+}
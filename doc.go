@@ -5,25 +5,46 @@ Totp_util helps you sign up for QR code "authenticator app" 2FA using a USB HID
 Key Features:
   - Parse TOTP QR Code URIs (note: this assumes you have a USB barcode scanner)
   - Allow TOTP profile editing for manual data entry or URI cleanup
+  - Keep a list of profiles in RAM for enrolling several accounts at once
   - Generate TOTP login codes
   - Source code is short, focused, and hopefully easy to audit
   - Ephemerality: totp_util works out of RAM and does not save any data to disk
+    unless you explicitly save profiles to a passphrase encrypted vault file
 
 Limitations:
   - For convenient QR code scanning, you need a USB HID 2D barcode scanner.
   - If you want to use totp_util fully airgapped, you will need to manually set
-    your workstation's clock because NTP won't be available.
-  - The text-mode interactive menu system uses line-buffered input to reduce
-    code complexity. That means arrow-key editing is not implemented.
+    your workstation's clock because NTP won't be available. Alternately, you
+    can scan a timestamp from the QR code clock (clock/index.html) to set an
+    in-memory clock offset without touching the system time.
+  - The line editor for the interactive prompt only works on Linux terminals.
+    Elsewhere, or with piped input, input is plain line-buffered text. The
+    editor assumes each line fits on one row of the terminal, so editing in
+    the middle of a long wrapped line (like a scanned URI) looks messy.
   - Go's runtime makes it difficult to sanitize buffers that have been used to
-    hold key material. If you care about clearing secrets out of RAM after
-    totp_util exits, your best option is to power down your computer.
+    hold key material. On Linux (x86, ARM, RISC-V, LoongArch, and s390x),
+    totp_util locks its memory into RAM (when running as root or with an
+    unlimited RLIMIT_MEMLOCK) so secrets don't get swapped to disk, and turns
+    off core dumps. The startup banner reports which protections are enabled.
+    Short-lived copies of decoded secrets (for making codes, fingerprints, and
+    comparisons) are held in SecretBuf byte slices that get overwritten after
+    use. But, that's as far as the wiping goes. Profiles keep their base32
+    secrets in Go strings, which can't be overwritten, and so do lines of
+    input (including vault passphrases and secrets typed at the prompt). Go's
+    crypto/hmac also keeps its own copy of the key while making a code. So,
+    clr, del, and quitting don't overwrite any secrets. They only drop the
+    profiles and ask the Go runtime to hand freed memory back to the OS, and
+    copies of secrets may linger in RAM until that memory gets reused.
+    If you care about clearing secrets out of RAM after totp_util exits, your
+    best option is still to power down your computer.
 
 Notes on Backups and Data Hygiene:
   - Totp_util is built with the assumption that you already use some kind of
     password manager, encrypted backup disk, or physical lockbox, and that you
     will keep a copy of your TOTP QR codes or decoded TOTP URIs in that place.
   - Totp_util uses an interactive prompt rather than command line arguments so
-    that TOTP secrets don't get written to your shell history file.
+    that TOTP secrets don't get written to your shell history file. To keep
+    a hand-typed secret out of the terminal scrollback too, use the secret
+    command, which reads it with echo off.
 */
 package main
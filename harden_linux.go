@@ -0,0 +1,51 @@
+//go:build linux && (386 || amd64 || arm || arm64 || loong64 || riscv64 || s390x)
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// The syscall package doesn't define these for most Linux architectures. The
+// values are from <asm-generic/resource.h>, <asm-generic/mman-common.h>, and
+// <linux/prctl.h>, and the build tag lists the architectures that use those
+// generic values. Others get the harden_other.go stub instead, since some of
+// the numbers differ there (RLIMIT_MEMLOCK is 9 on mips, and powerpc uses
+// different mlockall flags).
+const (
+	mclCurrent    = 1
+	mclFuture     = 2
+	prSetDumpable = 4
+	rlimitMemlock = 8
+	rlimInfinity  = ^uint64(0)
+)
+
+// hardenProcess tries to keep secrets out of swap and core dumps, and returns
+// a one line report of what worked:
+//   - mlockall locks the process's memory into RAM so it can't be swapped
+//     to disk. MCL_FUTURE covers memory allocated later, but if the
+//     RLIMIT_MEMLOCK limit is finite, the Go runtime would crash once the
+//     heap outgrew it. So, this only locks memory when running as root or
+//     when the limit is unlimited.
+//   - PR_SET_DUMPABLE=0 turns off core dumps and blocks ptrace attaching by
+//     other processes of the same user.
+func hardenProcess() string {
+	lock := "memory lock on"
+	var lim syscall.Rlimit
+	if err := syscall.Getrlimit(rlimitMemlock, &lim); err != nil {
+		lock = fmt.Sprintf("memory lock off (%v)", err)
+	} else if os.Geteuid() != 0 && lim.Cur != rlimInfinity {
+		lock = fmt.Sprintf("memory lock off (RLIMIT_MEMLOCK is %v bytes; "+
+			"run as root or use ulimit -l unlimited)", lim.Cur)
+	} else if err := syscall.Mlockall(mclCurrent | mclFuture); err != nil {
+		lock = fmt.Sprintf("memory lock off (%v)", err)
+	}
+	dump := "core dumps off"
+	_, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetDumpable, 0, 0)
+	if errno != 0 {
+		dump = fmt.Sprintf("core dumps on (%v)", errno)
+	}
+	return lock + ", " + dump
+}
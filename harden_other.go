@@ -0,0 +1,9 @@
+//go:build !linux || !(386 || amd64 || arm || arm64 || loong64 || riscv64 || s390x)
+
+package main
+
+// hardenProcess is only implemented for Linux, on the architectures listed in
+// harden_linux.go
+func hardenProcess() string {
+	return "memory lock off, core dumps on (not supported on this platform)"
+}
@@ -0,0 +1,62 @@
+package main
+
+import "strconv"
+
+// Struct Hotp holds the parameters needed to compute an HOTP code. Callers
+// should use Secret.Wipe() when they're done with it.
+type Hotp struct {
+	Secret    SecretBuf
+	Digits    int
+	Algorithm HmacAlgo
+	Counter   uint64
+}
+
+// NewHotp attempts to create an Hotp instance with the requested parameters.
+// If the parameters fail the validation checks, NewHotp returns a
+// *ValidationError, like NewTotp. Digits and algorithm use the same defaults
+// as NewTotp. The wiki says the counter parameter is required for HOTP, but in
+// the interest of lax input handling, an unspecified counter defaults to 0.
+func NewHotp(secret, digits, algorithm, counter string) (*Hotp, error) {
+	h := Hotp{}
+	v := ValidationError{}
+	var e *FieldError
+	// Validate digits=...
+	h.Digits, e = parseDigits(digits)
+	v.add(e)
+	// Validate algorithm=...
+	h.Algorithm, e = parseAlgorithm(algorithm)
+	v.add(e)
+	// Validate counter=...
+	h.Counter, e = parseCounter(counter)
+	v.add(e)
+	// Validate base32 secret
+	h.Secret, e = parseSecret(secret)
+	v.add(e)
+	// Bail out with an error if any of the validation checks failed
+	if err := v.result(); err != nil {
+		h.Secret.Wipe()
+		return nil, err
+	}
+	return &h, nil
+}
+
+// parseCounter validates a counter=... value. RFC4226 §5.1 says it's an 8-byte
+// counter. For unsupported values, the returned FieldError describes the
+// problem. Otherwise, it is nil.
+func parseCounter(counter string) (n uint64, e *FieldError) {
+	if counter == "" {
+		return
+	}
+	n, err := strconv.ParseUint(counter, 10, 64)
+	if err != nil {
+		n = 0
+		e = &FieldError{"counter", counter, "Counter should be empty or a" +
+			" decimal integer in the range 0..18446744073709551615."}
+	}
+	return
+}
+
+// Code returns a string with the HOTP code for the current counter value.
+func (h Hotp) Code() (string, error) {
+	return hotpCode(h.Secret, h.Algorithm, h.Digits, h.Counter)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// HotpVectors holds RFC4226 Appendix D test vectors. The secret is ASCII
+// "12345678901234567890", which is the same as key1 from totp_test.go.
+var HotpVectors []string = []string{
+	// HOTP codes for counter values 0..9
+	"755224", "287082", "359152", "969429", "338314",
+	"254676", "287922", "162583", "399871", "520489",
+}
+
+func Test_RFC4226_Appendix_D_test_vectors(t *testing.T) {
+	for i, want := range HotpVectors {
+		hotp, err := NewHotp(key1, "6", "SHA1", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		hotp.Counter = uint64(i)
+		code, err := hotp.Code()
+		if err != nil {
+			t.Error(err)
+		}
+		if want != code {
+			t.Error("\ni:", i, "\nwanted:", want, "\ngot:", code)
+		}
+	}
+}
+
+// HotpTruncated holds the 31-bit dynamically truncated values from RFC4226
+// Appendix D for counter values 0..9. Taking these mod 10^digits gives the
+// expected code for any supported number of digits.
+var HotpTruncated []int64 = []int64{
+	1284755224, 1094287082, 137359152, 1726969429, 1640338314,
+	868254676, 1918287922, 82162583, 673399871, 645520489,
+}
+
+// DigitsVectors holds codes derived from HotpTruncated for each code length
+var DigitsVectors map[string][]string = map[string][]string{
+	"6": {"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489"},
+	"7": {"4755224", "4287082", "7359152", "6969429", "0338314",
+		"8254676", "8287922", "2162583", "3399871", "5520489"},
+	"8": {"84755224", "94287082", "37359152", "26969429", "40338314",
+		"68254676", "18287922", "82162583", "73399871", "45520489"},
+	"9": {"284755224", "094287082", "137359152", "726969429", "640338314",
+		"868254676", "918287922", "082162583", "673399871", "645520489"},
+	"10": {"1284755224", "1094287082", "0137359152", "1726969429",
+		"1640338314", "0868254676", "1918287922", "0082162583",
+		"0673399871", "0645520489"},
+}
+
+// Each supported code length should match the RFC4226 truncated values
+func Test_RFC4226_digits_lengths(t *testing.T) {
+	for digits, codes := range DigitsVectors {
+		hotp, err := NewHotp(key1, digits, "SHA1", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, want := range codes {
+			hotp.Counter = uint64(i)
+			code, err := hotp.Code()
+			if err != nil {
+				t.Error(err)
+			}
+			if want != code {
+				t.Error("\ndigits:", digits, "i:", i, "\nwanted:", want,
+					"\ngot:", code)
+			}
+		}
+	}
+}
+
+// Dynamic truncation should give the RFC4226 31-bit values, which show up
+// whole in 10 digit codes, and the other code lengths should be those values
+// mod 10^digits
+func Test_RFC4226_dynamic_truncation(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	for i, truncated := range HotpTruncated {
+		code, err := hotpCode(secret, HmacSha1, 10, uint64(i))
+		if want := fmt.Sprintf("%010d", truncated); err != nil || code != want {
+			t.Error("\ni:", i, "\nwanted:", want, "\ngot:", code, err)
+		}
+		for digits, codes := range DigitsVectors {
+			n, _ := strconv.Atoi(digits)
+			modulus := int64(1)
+			for j := 0; j < n; j++ {
+				modulus *= 10
+			}
+			want := fmt.Sprintf("%0*d", n, truncated%modulus)
+			if codes[i] != want {
+				t.Error("\ndigits:", digits, "i:", i, "\nwanted:", want,
+					"\ngot:", codes[i])
+			}
+		}
+	}
+}
+
+// Counter should parse from a decimal string
+func Test_hotp_counter(t *testing.T) {
+	hotp, err := NewHotp(key1, "", "", "9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := hotp.Code()
+	if err != nil || code != HotpVectors[9] {
+		t.Error("\nwanted:", HotpVectors[9], "\ngot:", code, err)
+	}
+}
+
+// Attempting to use a non-integer counter should fail
+func Test_unsupported_counter(t *testing.T) {
+	wantError := "Counter should be empty or a decimal integer"
+	for _, counter := range []string{"-1", "x", "18446744073709551616"} {
+		_, err := NewHotp(key1, "", "", counter)
+		if err == nil || !strings.Contains(err.Error(), wantError) {
+			t.Error("\ntried:", counter, "\nwanted:", wantError, "\ngot:", err)
+		}
+		var f *FieldError
+		if !errors.As(err, &f) || f.Field != "counter" || f.Value != counter {
+			t.Error("\ntried:", counter, "\nwanted: counter *FieldError\ngot:", f)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+/*
+Package gf256 does arithmetic over the Galois Field GF(2^8) with generator
+𝛼=2 and prime polynomial 0x11d.
+
+This is the field used by QR code Reed-Solomon error correction (see
+qr/reedsolomon.go and clock/research/gf2811d.py), and the shamir package uses
+it for secret sharing. Addition and subtraction are both xor, so they don't
+need functions here.
+*/
+package gf256
+
+// logTable and expTable are logarithm and exponential tables for doing
+// multiplication and division. expTable has 512 entries so that the sum of two
+// logarithms can be used as an index without reducing it mod 255.
+var logTable, expTable = func() (log [256]int, exp [512]byte) {
+	n := 1
+	for i := 0; i < 255; i++ {
+		exp[i] = byte(n)
+		log[n] = i
+		n = (n << 1) ^ (((n >> 7) & 1) * 0x11d) // Multiply n by 𝛼=2 mod 11d
+	}
+	for i := 255; i < 512; i++ {
+		exp[i] = exp[i-255]
+	}
+	return
+}()
+
+// Exp returns 𝛼^i. Callers make sure i isn't negative.
+func Exp(i int) byte {
+	return expTable[i%255]
+}
+
+// Log returns the logarithm of a, so that Exp(Log(a)) == a. Callers make sure
+// a isn't 0.
+func Log(a byte) int {
+	return logTable[a]
+}
+
+// Mul multiplies a and b
+func Mul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return expTable[logTable[a]+logTable[b]]
+}
+
+// Div divides a by b. Callers make sure b isn't 0.
+func Div(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return expTable[logTable[a]+255-logTable[b]]
+}
@@ -0,0 +1,51 @@
+package gf256
+
+import "testing"
+
+// Exp and Log should be inverses, and powers of 𝛼 should repeat every 255
+func TestExpLog(t *testing.T) {
+	for i := 0; i < 255; i++ {
+		if got := Log(Exp(i)); got != i {
+			t.Fatal("\ni:", i, "\ngot:", got)
+		}
+		if Exp(i) != Exp(i+255) {
+			t.Fatal("\ni:", i, "\nwanted: period 255")
+		}
+	}
+	// 𝛼^8 = 0x100 mod 0x11d = 0x1d
+	if got := Exp(8); got != 0x1d {
+		t.Errorf("\nwanted: 0x1d\ngot:    %#x", got)
+	}
+}
+
+// Multiplication should match shift-and-xor with reduction mod 0x11d
+func TestMul(t *testing.T) {
+	for a := 0; a < 256; a++ {
+		for b := 0; b < 256; b++ {
+			want, x := 0, a
+			for bit := 0; bit < 8; bit++ {
+				if b&(1<<bit) != 0 {
+					want ^= x
+				}
+				x <<= 1
+				if x&0x100 != 0 {
+					x ^= 0x11d
+				}
+			}
+			if got := Mul(byte(a), byte(b)); got != byte(want) {
+				t.Fatal("\na:", a, "b:", b, "\nwanted:", want, "\ngot:", got)
+			}
+		}
+	}
+}
+
+// Division should undo multiplication for every nonzero pair
+func TestDiv(t *testing.T) {
+	for a := 0; a < 256; a++ {
+		for b := 1; b < 256; b++ {
+			if got := Div(Mul(byte(a), byte(b)), byte(b)); got != byte(a) {
+				t.Fatal("\na:", a, "b:", b, "\ngot:", got)
+			}
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// maxHistory is how many lines the line editor remembers
+const maxHistory = 100
+
+// LineEditor reads lines from a terminal in raw mode, with cursor movement,
+// deletion, and history. It does its own echo, so the terminal needs to be in
+// raw mode (see makeRaw). LineEditor implements io.Reader, giving one line of
+// input at a time with a "\n" on the end, so it can stand in for stdin.
+//
+// Keys:
+//
+//	Left, Right, Ctrl-B, Ctrl-F   move the cursor
+//	Home, End, Ctrl-A, Ctrl-E     move to the start or end of the line
+//	Backspace, Delete, Ctrl-D     delete before or at the cursor
+//	Ctrl-U, Ctrl-K                delete to the start or end of the line
+//	Ctrl-W                        delete the word before the cursor
+//	Up, Down, Ctrl-P, Ctrl-N      recall lines from history
+//	Ctrl-D (empty line), Ctrl-C   end the input
+//
+// The history lives only in RAM. Lines typed with echo off, and lines that
+// keep rejects, don't go in it.
+type LineEditor struct {
+	in   *bufio.Reader
+	out  io.Writer
+	keep func(line string) bool
+	// hidden is set while echo is off. It gets changed by the session's
+	// goroutine while the editor's goroutine is reading keys, so it's atomic.
+	hidden atomic.Bool
+
+	history []string
+	// pending holds the part of the current line that hasn't been read yet
+	pending []byte
+	// buf holds the line being edited, and pos is the cursor position in it.
+	// histIndex is the history entry being shown (len(history) for the new
+	// line), and draft saves the new line while looking through history.
+	buf       []rune
+	pos       int
+	histIndex int
+	draft     []rune
+	// afterCR notes that the last key was a CR, so that the LF of a CRLF
+	// (from some barcode scanners) doesn't count as a second Enter
+	afterCR bool
+}
+
+// NewLineEditor makes a line editor that reads keys from in and echoes to
+// out. Lines only go in the history if keep returns true for them.
+func NewLineEditor(in io.Reader, out io.Writer,
+	keep func(line string) bool) *LineEditor {
+	return &LineEditor{in: bufio.NewReader(in), out: out, keep: keep}
+}
+
+// SetEcho turns echo of typed keys on or off. This has the same signature as
+// setEcho, so the session can use it for passphrase entry.
+func (e *LineEditor) SetEcho(on bool) error {
+	e.hidden.Store(!on)
+	return nil
+}
+
+// Read gives the next line of input, ending with "\n"
+func (e *LineEditor) Read(p []byte) (int, error) {
+	if len(e.pending) == 0 {
+		line, err := e.ReadLine()
+		if err != nil {
+			return 0, err
+		}
+		e.pending = append([]byte(line), '\n')
+	}
+	n := copy(p, e.pending)
+	SecretBuf(e.pending[:n]).Wipe()
+	e.pending = e.pending[n:]
+	return n, nil
+}
+
+// ctrl returns the character that the Ctrl key makes with c
+func ctrl(c rune) rune {
+	return c & 0x1f
+}
+
+// ReadLine reads keys and edits the line until Enter. Ctrl-C, or Ctrl-D on
+// an empty line, ends the input with io.EOF.
+func (e *LineEditor) ReadLine() (string, error) {
+	e.buf, e.pos, e.histIndex, e.draft = e.buf[:0], 0, len(e.history), nil
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		afterCR := e.afterCR
+		e.afterCR = r == '\r'
+		switch r {
+		case '\n':
+			if afterCR {
+				continue
+			}
+			fallthrough
+		case '\r':
+			return e.finishLine(), nil
+		case ctrl('C'):
+			e.write("^C\n")
+			return "", io.EOF
+		case ctrl('D'):
+			if len(e.buf) == 0 {
+				e.write("\n")
+				return "", io.EOF
+			}
+			e.delete(e.pos, e.pos+1)
+		case ctrl('A'):
+			e.moveTo(0)
+		case ctrl('E'):
+			e.moveTo(len(e.buf))
+		case ctrl('B'):
+			e.moveTo(e.pos - 1)
+		case ctrl('F'):
+			e.moveTo(e.pos + 1)
+		case ctrl('H'), 0x7f:
+			e.delete(e.pos-1, e.pos)
+		case ctrl('U'):
+			e.delete(0, e.pos)
+		case ctrl('K'):
+			e.delete(e.pos, len(e.buf))
+		case ctrl('W'):
+			e.delete(e.wordStart(), e.pos)
+		case ctrl('P'):
+			e.recall(-1)
+		case ctrl('N'):
+			e.recall(1)
+		case 0x1b:
+			e.escape()
+		default:
+			if r >= ' ' {
+				e.insert(r)
+			}
+		}
+	}
+}
+
+// finishLine ends the line being edited, adds it to the history if it
+// belongs there, and returns it
+func (e *LineEditor) finishLine() string {
+	line := string(e.buf)
+	// The session prints its own newline after a line typed with echo off
+	if !e.hidden.Load() {
+		e.write("\n")
+		if line != "" && e.keep(line) &&
+			(len(e.history) == 0 || e.history[len(e.history)-1] != line) {
+			e.history = append(e.history, line)
+			if len(e.history) > maxHistory {
+				e.history = e.history[1:]
+			}
+		}
+	}
+	for i := range e.buf {
+		e.buf[i] = 0
+	}
+	return line
+}
+
+// escape handles the rest of an escape sequence for a cursor key, like
+// ESC [ A for Up or ESC [ 3 ~ for Delete. Unknown sequences get ignored. If
+// ESC isn't followed by "[" or "O", it was a stray Esc key press, so the key
+// after it gets put back to be handled normally.
+func (e *LineEditor) escape() {
+	r, _, err := e.in.ReadRune()
+	if err != nil {
+		return
+	}
+	if r != '[' && r != 'O' {
+		e.in.UnreadRune()
+		return
+	}
+	param := 0
+	for {
+		r, _, err = e.in.ReadRune()
+		if err != nil {
+			return
+		}
+		if r >= '0' && r <= '9' {
+			param = param*10 + int(r-'0')
+			continue
+		}
+		if r >= 0x40 && r <= 0x7e {
+			break
+		}
+	}
+	switch {
+	case r == 'A':
+		e.recall(-1)
+	case r == 'B':
+		e.recall(1)
+	case r == 'C':
+		e.moveTo(e.pos + 1)
+	case r == 'D':
+		e.moveTo(e.pos - 1)
+	case r == 'H' || (r == '~' && (param == 1 || param == 7)):
+		e.moveTo(0)
+	case r == 'F' || (r == '~' && (param == 4 || param == 8)):
+		e.moveTo(len(e.buf))
+	case r == '~' && param == 3:
+		e.delete(e.pos, e.pos+1)
+	}
+}
+
+// wordStart finds the start of the word before the cursor for Ctrl-W. Words
+// end at spaces and at "=", so Ctrl-W on "secret=ABC" leaves "secret=".
+func (e *LineEditor) wordStart() int {
+	i := e.pos
+	for i > 0 && (e.buf[i-1] == ' ' || e.buf[i-1] == '=') {
+		i--
+	}
+	for i > 0 && e.buf[i-1] != ' ' && e.buf[i-1] != '=' {
+		i--
+	}
+	return i
+}
+
+// insert adds r at the cursor
+func (e *LineEditor) insert(r rune) {
+	old := e.pos
+	e.buf = append(e.buf, 0)
+	copy(e.buf[e.pos+1:], e.buf[e.pos:])
+	e.buf[e.pos] = r
+	e.pos++
+	if e.pos == len(e.buf) {
+		// Typing at the end of the line, which is what a barcode scanner
+		// does, only needs an echo, not a redraw
+		e.write(string(r))
+	} else {
+		e.redraw(old)
+	}
+}
+
+// delete removes buf[from:to], after clipping the range to the line, and
+// leaves the cursor at from
+func (e *LineEditor) delete(from, to int) {
+	from, to = max(from, 0), min(to, len(e.buf))
+	if from >= to {
+		return
+	}
+	old := e.pos
+	n := copy(e.buf[from:], e.buf[to:])
+	for i := from + n; i < len(e.buf); i++ {
+		e.buf[i] = 0
+	}
+	e.buf = e.buf[:from+n]
+	e.pos = from
+	e.redraw(old)
+}
+
+// moveTo moves the cursor to position n, if it's within the line
+func (e *LineEditor) moveTo(n int) {
+	if n < 0 || n > len(e.buf) || n == e.pos {
+		return
+	}
+	e.moveCursor(n - e.pos)
+	e.pos = n
+}
+
+// recall replaces the line with the previous (dir -1) or next (dir 1) history
+// entry. History stays out of reach while echo is off.
+func (e *LineEditor) recall(dir int) {
+	i := e.histIndex + dir
+	if e.hidden.Load() || i < 0 || i > len(e.history) {
+		return
+	}
+	if e.histIndex == len(e.history) {
+		e.draft = append([]rune{}, e.buf...)
+	}
+	e.histIndex = i
+	line := e.draft
+	if i < len(e.history) {
+		line = []rune(e.history[i])
+	}
+	old := e.pos
+	e.buf = append(e.buf[:0], line...)
+	e.pos = len(e.buf)
+	e.redraw(old)
+}
+
+// redraw redraws the line after an edit, given where the cursor was before
+// it. The cursor moves are relative to where the line starts, so the editor
+// doesn't need to know how long the prompt is. This assumes the line fits on
+// one row of the terminal.
+func (e *LineEditor) redraw(oldPos int) {
+	e.moveCursor(-oldPos)
+	e.write(string(e.buf) + "\x1b[K")
+	e.moveCursor(e.pos - len(e.buf))
+}
+
+// moveCursor moves the terminal cursor n columns right (or left, for
+// negative n)
+func (e *LineEditor) moveCursor(n int) {
+	switch {
+	case n > 0:
+		e.write(fmt.Sprintf("\x1b[%dC", n))
+	case n < 0:
+		e.write(fmt.Sprintf("\x1b[%dD", -n))
+	}
+}
+
+// write echoes s, unless echo is off
+func (e *LineEditor) write(s string) {
+	if !e.hidden.Load() {
+		io.WriteString(e.out, s)
+	}
+}
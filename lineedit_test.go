@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+// readAllLines runs the line editor on keys until the input ends, and
+// returns the lines along with the echo
+func readAllLines(e *LineEditor) (lines []string, echo string) {
+	for {
+		line, err := e.ReadLine()
+		if err != nil {
+			break
+		}
+		lines = append(lines, line)
+	}
+	return lines, e.out.(*strings.Builder).String()
+}
+
+func newTestEditor(keys string) *LineEditor {
+	return NewLineEditor(strings.NewReader(keys), &strings.Builder{},
+		func(string) bool { return true })
+}
+
+// Editing keys should move the cursor and delete the right characters
+func TestLineEditorEditing(t *testing.T) {
+	cases := []struct {
+		Keys, Want string
+	}{
+		{"abc\r", "abc"},
+		{"ac\x1b[Db\r", "abc"},                           // Left, then insert
+		{"ac\x02b\x06d\r", "abcd"},                       // Ctrl-B, Ctrl-F
+		{"bc\x01a\x05d\r", "abcd"},                       // Ctrl-A, Ctrl-E
+		{"bc\x1b[Ha\x1b[Fd\r", "abcd"},                   // Home, End
+		{"bc\x1b[1~a\x1b[4~d\r", "abcd"},                 // Home, End (vt style)
+		{"bc\x1bOHa\x1bOFd\r", "abcd"},                   // Home, End (application mode)
+		{"abx\x7fc\r", "abc"},                            // Backspace
+		{"abxc\x1b[D\x08\r", "abc"},                      // Ctrl-H in the middle
+		{"abxc\x1b[D\x1b[D\x1b[3~\r", "abc"},             // Delete
+		{"abxc\x1b[D\x1b[D\x04\r", "abc"},                // Ctrl-D in the middle
+		{"junk\x15abc\r", "abc"},                         // Ctrl-U
+		{"abjunk\x1b[D\x1b[D\x15\r", "nk"},               // Ctrl-U in the middle
+		{"abcjunk\x1b[D\x1b[D\x1b[D\x1b[D\x0b\r", "abc"}, // Ctrl-K
+		{"secret=ABC\x17XYZ\r", "secret=XYZ"},            // Ctrl-W stops at "="
+		{"name=a b  \x17\x17\r", "name="},                // Ctrl-W skips spaces
+		{"\x7f\x1b[D\x01\x1b[3~\x17\x15abc\r", "abc"},    // Nothing to delete
+		{"a\x1b[5~b\x1b[Zc\r", "abc"},                    // Unknown keys
+		{"ab\x1bc\r", "abc"},                             // Stray Esc
+		{"ab\x1b\x1b[Dc\r", "acb"},                       // Esc, then Left
+		{"abc\r\n", "abc"},                               // CRLF is one Enter
+		{"abc\n", "abc"},
+	}
+	for _, c := range cases {
+		lines, _ := readAllLines(newTestEditor(c.Keys))
+		if len(lines) != 1 || lines[0] != c.Want {
+			t.Error("\ntried:", strings.ToValidUTF8(c.Keys, "?"), "\nwanted:",
+				c.Want, "\ngot:", lines)
+		}
+	}
+}
+
+// Ctrl-C and Ctrl-D on an empty line should end the input
+func TestLineEditorEOF(t *testing.T) {
+	for _, keys := range []string{"abc\r\x03", "abc\r\x04", "abc\r"} {
+		e := newTestEditor(keys)
+		lines, _ := readAllLines(e)
+		if len(lines) != 1 || lines[0] != "abc" {
+			t.Error("\ntried:", keys, "\ngot:", lines)
+		}
+	}
+	// Read should act like reading lines from a file
+	scanner := bufio.NewScanner(newTestEditor("ls\rsel=1\r\x04"))
+	got := []string{}
+	for scanner.Scan() {
+		got = append(got, scanner.Text())
+	}
+	if strings.Join(got, ",") != "ls,sel=1" {
+		t.Error("\nwanted: ls,sel=1\ngot:", got)
+	}
+}
+
+// History should recall earlier lines, skipping ones that keep rejects and
+// ones typed with echo off
+func TestLineEditorHistory(t *testing.T) {
+	keys := "ls\r" + "secret=ABC\r" + "ls\r" + "sel=1\r" + "bogus\r" +
+		"\x1b[A\r" + // Up: sel=1
+		"\x1b[A\x1b[A\r" + // sel=1 again (no repeat), then ls
+		"\x1b[A\x1b[A\x1b[A\x1b[A\r" + // Up past the start stays on ls
+		"dr\x10\x0e\x1b[Baft\r" + // Down returns to the draft
+		"\x1b[B\r" // Down past the end does nothing
+	e := NewLineEditor(strings.NewReader(keys), &strings.Builder{},
+		keepInHistory)
+	lines, _ := readAllLines(e)
+	want := []string{"ls", "secret=ABC", "ls", "sel=1", "bogus", "sel=1", "ls",
+		"ls", "draft", ""}
+	if strings.Join(lines, ",") != strings.Join(want, ",") {
+		t.Error("\nwanted:", want, "\ngot:", lines)
+	}
+	if strings.Join(e.history, ",") != "ls,sel=1,ls" {
+		t.Error("\nwanted: ls,sel=1,ls\ngot:", e.history)
+	}
+}
+
+// With echo off, nothing should get echoed or remembered, and history
+// shouldn't be reachable
+func TestLineEditorHidden(t *testing.T) {
+	out := &strings.Builder{}
+	e := NewLineEditor(strings.NewReader("ls\rhunter2\x1b[A\r"), out,
+		func(string) bool { return true })
+	if line, _ := e.ReadLine(); line != "ls" {
+		t.Fatal("\nwanted: ls\ngot:", line)
+	}
+	e.SetEcho(false)
+	line, _ := e.ReadLine()
+	e.SetEcho(true)
+	if line != "hunter2" || strings.Contains(out.String(), "hunter") ||
+		len(e.history) != 1 {
+		t.Error("\nwanted: hunter2 (hidden)\ngot:", line, out.String(), e.history)
+	}
+}
+
+// Typing at the end of the line should just echo, and edits in the middle
+// should redraw from the start of the line
+func TestLineEditorEcho(t *testing.T) {
+	_, echo := readAllLines(newTestEditor("ac\x1b[Db\x1b[C\x15\r"))
+	want := "ac" + "\x1b[1D" + // Type, then Left
+		"\x1b[1Dabc\x1b[K\x1b[1D" + // Insert b and redraw
+		"\x1b[1C" + // Right
+		"\x1b[3D\x1b[K" + // Ctrl-U
+		"\n"
+	if echo != want {
+		t.Errorf("\nwanted: %q\ngot:    %q", want, echo)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"net/url" // For QueryUnescape()
+	"regexp"
+	"strings"
+)
+
+// Severity is an enum for how much a lint Finding matters
+type Severity int
+
+const (
+	// SeverityInfo is for things that are technically fine but unusual
+	SeverityInfo Severity = iota
+	// SeverityWarning is for things some authenticator apps may mishandle
+	SeverityWarning
+	// SeverityError is for things that will keep codes from working
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return "ERROR"
+}
+
+// Finding is one problem noticed by LintURI. Field is the name of the query
+// parameter (or "label" or "uri") that the finding is about.
+type Finding struct {
+	Severity Severity
+	Field    string
+	Message  string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%v: %v: %v", f.Severity, f.Field, f.Message)
+}
+
+// LintURI is a strict companion to NewProfileFromURI. Where NewProfileFromURI
+// quietly makes the best of whatever it gets, LintURI reports everything it
+// notices that doesn't match the Key URI Format wiki page, or that is likely
+// to cause trouble with authenticator apps. Findings are listed in the order
+// they appear in the URI, followed by findings about things that are missing
+// (including the issuer prefix of the label, which only matters when the
+// query has an issuer).
+func LintURI(uri string) (findings []Finding) {
+	add := func(s Severity, field, format string, a ...any) {
+		findings = append(findings, Finding{s, field, fmt.Sprintf(format, a...)})
+	}
+	otpQRCodeRE := regexp.MustCompile(`^otpauth://(totp|hotp)/([^?]*)\?(.*)`)
+	submatches := otpQRCodeRE.FindStringSubmatch(uri)
+	if len(submatches) < 4 {
+		add(SeverityError, "uri",
+			"does not match otpauth://totp/<label>?<query> (or hotp)")
+		return
+	}
+	otpType, label, query := submatches[1], submatches[2], submatches[3]
+	// Check the label
+	labelIssuer, spaces, account := splitLabel(label)
+	if unesc, err := url.PathUnescape(labelIssuer); err == nil {
+		labelIssuer = unesc
+	}
+	if spaces != "" {
+		add(SeverityInfo, "label", "spaces after the issuer prefix")
+	}
+	if strings.Contains(labelIssuer, ":") {
+		// The issuer prefix ends at the last ":", but some apps split at
+		// the first one
+		add(SeverityWarning, "label", "more than one \":\"")
+	}
+	if unesc, err := url.PathUnescape(account); err != nil {
+		add(SeverityWarning, "label", "account has bad %%-escaping")
+	} else if unesc == "" {
+		add(SeverityWarning, "label", "account name is blank")
+	}
+	// Check the query parameters
+	seen := map[string]bool{}
+	for _, kv := range strings.Split(query, "&") {
+		if kv == "" {
+			continue
+		}
+		key, val, _ := strings.Cut(kv, "=")
+		if seen[key] {
+			severity := SeverityWarning
+			if key == "secret" {
+				// Apps disagree about whether the first or last one wins
+				severity = SeverityError
+			}
+			add(severity, key, "parameter appears more than once")
+			continue
+		}
+		seen[key] = true
+		switch key {
+		case "secret":
+			lintSecret(val, add)
+		case "issuer":
+			unesc, err := url.QueryUnescape(val)
+			if err != nil {
+				add(SeverityWarning, "issuer", "bad %%-escaping")
+			} else if labelIssuer != "" && unesc != labelIssuer {
+				add(SeverityWarning, "issuer",
+					"%q does not match label prefix %q", unesc, labelIssuer)
+			}
+		case "algorithm":
+			if _, e := parseAlgorithm(strings.ToUpper(val)); e != nil {
+				add(SeverityError, "algorithm", "unsupported value %q", val)
+			} else if val != strings.ToUpper(val) {
+				add(SeverityWarning, "algorithm", "%q should be uppercase", val)
+			} else if val != "SHA1" {
+				add(SeverityInfo, "algorithm",
+					"%v is not supported by some apps", val)
+			}
+		case "digits":
+			if _, e := parseDigits(val); e != nil {
+				add(SeverityError, "digits", "unsupported value %q", val)
+			} else if val != "6" && val != "8" {
+				add(SeverityWarning, "digits",
+					"%v is not supported by most apps (6 or 8 is safest)", val)
+			}
+		case "period":
+			if otpType == "hotp" {
+				add(SeverityWarning, "period", "not used for HOTP")
+			} else if _, e := parsePeriod(val); e != nil {
+				add(SeverityError, "period", "unsupported value %q", val)
+			} else if val != "30" {
+				add(SeverityWarning, "period",
+					"%v is not supported by many apps (30 is safest)", val)
+			}
+		case "counter":
+			if otpType != "hotp" {
+				add(SeverityWarning, "counter", "not used for TOTP")
+			} else if _, e := parseCounter(val); e != nil {
+				add(SeverityError, "counter", "unsupported value %q", val)
+			}
+		default:
+			add(SeverityWarning, key, "unknown parameter")
+		}
+	}
+	// Check for missing parameters
+	if !seen["secret"] {
+		add(SeverityError, "secret", "missing")
+	}
+	if otpType == "hotp" && !seen["counter"] {
+		add(SeverityError, "counter", "missing (required for HOTP)")
+	}
+	if !seen["issuer"] {
+		add(SeverityInfo, "issuer", "missing (recommended by the wiki)")
+	} else if labelIssuer == "" {
+		add(SeverityInfo, "label",
+			"no issuer prefix (recommended for backward compatibility)")
+	}
+	return
+}
+
+// lintSecret checks the secret= parameter
+func lintSecret(secret string, add func(Severity, string, string, ...any)) {
+	key, e := parseSecret(secret)
+	defer key.Wipe()
+	if e != nil {
+		add(SeverityError, "secret", "%v", e.Message)
+		return
+	}
+	unesc, _ := url.QueryUnescape(secret)
+	if strings.HasSuffix(unesc, "=") {
+		add(SeverityWarning, "secret",
+			"has \"=\" padding (the wiki says omit it)")
+	}
+	if unesc != strings.ToUpper(unesc) {
+		add(SeverityInfo, "secret", "lowercase base32")
+	}
+	if len(key) < 16 {
+		add(SeverityWarning, "secret",
+			"only %v bytes (RFC4226 §4 requires at least 16)", len(key))
+	}
+}
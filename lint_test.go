@@ -0,0 +1,77 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// A well formed URI with a 20 byte secret should have no findings
+func TestLintClean(t *testing.T) {
+	uri := "otpauth://totp/ACME%20Co:john.doe@acme?" +
+		"secret=HXDMVJECJJWSRB3HWIZR4IFUGFTMXBOZ&issuer=ACME%20Co" +
+		"&algorithm=SHA1&digits=6&period=30"
+	if got := LintURI(uri); len(got) != 0 {
+		t.Error("\nwanted: no findings \ngot:", got)
+	}
+}
+
+// Each kind of problem should be reported, in URI order
+func TestLintFindings(t *testing.T) {
+	secret := "HXDMVJECJJWSRB3HWIZR4IFUGFTMXBOZ"
+	cases := []struct {
+		URI  string
+		Want []Finding
+	}{
+		{"otpauth://totp/Example:alice?issuer=Example",
+			[]Finding{{SeverityError, "secret", "missing"}}},
+		{"otpauth://totp/Example:alice?secret=" + secret + "&issuer=Example" +
+			"&secret=" + secret,
+			[]Finding{{SeverityError, "secret",
+				"parameter appears more than once"}}},
+		{"otpauth://totp/Example:alice?secret=" + secret + "&issuer=Other",
+			[]Finding{{SeverityWarning, "issuer",
+				"\"Other\" does not match label prefix \"Example\""}}},
+		// The issuer prefix runs to the last ":", like in NewProfileFromURI
+		{"otpauth://totp/A:B:alice?secret=" + secret + "&issuer=A%3AB",
+			[]Finding{{SeverityWarning, "label", "more than one \":\""}}},
+		{"otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXPAA%3D%3D%3D%3D" +
+			"%3D%3D&issuer=Example",
+			[]Finding{
+				{SeverityWarning, "secret",
+					"has \"=\" padding (the wiki says omit it)"},
+				{SeverityWarning, "secret",
+					"only 11 bytes (RFC4226 §4 requires at least 16)"}}},
+		{"otpauth://totp/Example:alice?secret=" + secret + "&issuer=Example" +
+			"&algorithm=sha256&period=15&title=x",
+			[]Finding{
+				{SeverityWarning, "algorithm", "\"sha256\" should be uppercase"},
+				{SeverityWarning, "period",
+					"15 is not supported by many apps (30 is safest)"},
+				{SeverityWarning, "title", "unknown parameter"}}},
+		{"otpauth://totp/alice?secret=" + secret + "&digits=5&period=0",
+			[]Finding{
+				{SeverityError, "digits", "unsupported value \"5\""},
+				{SeverityError, "period", "unsupported value \"0\""},
+				{SeverityInfo, "issuer", "missing (recommended by the wiki)"}}},
+		{"otpauth://hotp/Example:?secret=" + secret + "&issuer=Example",
+			[]Finding{
+				{SeverityWarning, "label", "account name is blank"},
+				{SeverityError, "counter", "missing (required for HOTP)"}}},
+		{"otpauth://hotp/alice?secret=" + secret + "&issuer=Example" +
+			"&counter=x&period=30",
+			[]Finding{
+				{SeverityError, "counter", "unsupported value \"x\""},
+				{SeverityWarning, "period", "not used for HOTP"},
+				{SeverityInfo, "label",
+					"no issuer prefix (recommended for backward compatibility)"}}},
+		{"otpauth://totp/alice",
+			[]Finding{{SeverityError, "uri",
+				"does not match otpauth://totp/<label>?<query> (or hotp)"}}},
+	}
+	for i, v := range cases {
+		got := LintURI(v.URI)
+		if !reflect.DeepEqual(v.Want, got) {
+			t.Error("\ni:", i, "\nwanted:", v.Want, "\ngot:   ", got)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url" // For PathUnescape()
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Migration holds the decoded contents of one Google Authenticator "Transfer
+// accounts" QR code. When there are too many accounts to fit in one QR code,
+// the export gets split into a sequence of QR codes that share a BatchID, with
+// BatchIndex counting up from 0 to BatchSize-1.
+type Migration struct {
+	Profiles   []Profile
+	Version    int64
+	BatchSize  int64
+	BatchIndex int64
+	BatchID    int64
+}
+
+// maxBatchSize limits how many QR codes an export can claim to be split into.
+// Google Authenticator puts around 10 accounts in each QR code, so real
+// exports need far fewer than this. The limit keeps a crafted batch size from
+// making the list of missing QR codes grow without end.
+const maxBatchSize = 100
+
+// NewMigrationFromURI decodes an otpauth-migration:// URI. The format is:
+//
+//	otpauth-migration://offline?data=<url-escaped-base64-protobuf>
+//
+// Google doesn't document this, but the protobuf schema is well known from
+// various open source export tools. Summarizing the parts that matter here:
+//
+//	message MigrationPayload {
+//	  repeated OtpParameters otp_parameters = 1;
+//	  int32 version = 2;
+//	  int32 batch_size = 3;
+//	  int32 batch_index = 4;
+//	  int32 batch_id = 5;
+//	}
+//	message OtpParameters {
+//	  bytes secret = 1;
+//	  string name = 2;
+//	  string issuer = 3;
+//	  Algorithm algorithm = 4;  // 1=SHA1, 2=SHA256, 3=SHA512, 4=MD5
+//	  DigitCount digits = 5;    // 1=six, 2=eight
+//	  OtpType type = 6;         // 1=HOTP, 2=TOTP
+//	  int64 counter = 7;
+//	}
+//
+// The payload only uses varint and length-delimited fields, so rather than
+// adding a protobuf library dependency, this uses the small reader below.
+// Unknown fields get skipped. The migration format has no period field, so
+// TOTP entries always use the default 30 second period.
+func NewMigrationFromURI(uri string) (m Migration, err error) {
+	migrationRE := regexp.MustCompile(`^otpauth-migration://offline\?(.*)`)
+	submatches := migrationRE.FindStringSubmatch(uri)
+	if len(submatches) < 2 {
+		err = errors.New("URI does not match otpauth-migration://offline?...")
+		return
+	}
+	data := ""
+	for _, v := range strings.Split(submatches[1], "&") {
+		if strings.HasPrefix(v, "data=") {
+			data = v[len("data="):]
+		}
+	}
+	// Use PathUnescape rather than QueryUnescape because the data is base64,
+	// and QueryUnescape would turn any unescaped "+" into " "
+	if unesc, e := url.PathUnescape(data); e == nil {
+		data = unesc
+	}
+	if data == "" {
+		err = errors.New("Migration URI has no data parameter")
+		return
+	}
+	// Tolerate missing "=" padding
+	payload, e := base64.RawStdEncoding.DecodeString(strings.TrimRight(data, "="))
+	if e != nil {
+		err = fmt.Errorf("Migration data is weird (base64 decode failed: %v)", e)
+		return
+	}
+	// The payload holds raw secrets, so wipe it once they've been re-encoded
+	// as base32 for the profiles
+	defer SecretBuf(payload).Wipe()
+	r := protoReader{buf: payload}
+	for !r.done() {
+		field, wireType, e := r.key()
+		if e != nil {
+			err = e
+			return
+		}
+		switch {
+		case field == 1 && wireType == wireBytes:
+			var b []byte
+			if b, err = r.bytes(); err != nil {
+				return
+			}
+			var p Profile
+			if p, err = migrationProfile(b); err != nil {
+				return
+			}
+			m.Profiles = append(m.Profiles, p)
+		case field == 2 && wireType == wireVarint:
+			m.Version, err = r.varintInt64()
+		case field == 3 && wireType == wireVarint:
+			m.BatchSize, err = r.varintInt64()
+		case field == 4 && wireType == wireVarint:
+			m.BatchIndex, err = r.varintInt64()
+		case field == 5 && wireType == wireVarint:
+			m.BatchID, err = r.varintInt64()
+		default:
+			err = r.skip(wireType)
+		}
+		if err != nil {
+			return
+		}
+	}
+	// Exports that fit in one QR code may leave out the batch fields
+	if m.BatchSize < 1 {
+		m.BatchSize = 1
+	}
+	if m.BatchSize > maxBatchSize {
+		err = fmt.Errorf("Migration data is weird (batch size %v is over %v)",
+			m.BatchSize, maxBatchSize)
+		return
+	}
+	if m.BatchIndex < 0 || m.BatchIndex >= m.BatchSize {
+		err = fmt.Errorf("Migration batch index %v is out of range for size %v",
+			m.BatchIndex, m.BatchSize)
+	}
+	return
+}
+
+// migrationProfile decodes an OtpParameters message into a Profile
+func migrationProfile(buf []byte) (p Profile, err error) {
+	var secret []byte
+	var name string
+	r := protoReader{buf: buf}
+	for !r.done() {
+		field, wireType, e := r.key()
+		if e != nil {
+			return p, e
+		}
+		var n uint64
+		switch {
+		case field == 1 && wireType == wireBytes:
+			secret, err = r.bytes()
+		case field == 2 && wireType == wireBytes:
+			var b []byte
+			b, err = r.bytes()
+			name = string(b)
+		case field == 3 && wireType == wireBytes:
+			var b []byte
+			b, err = r.bytes()
+			p.Issuer = string(b)
+		case field == 4 && wireType == wireVarint:
+			n, err = r.varint()
+			switch n {
+			case 1:
+				p.Algorithm = "SHA1"
+			case 2:
+				p.Algorithm = "SHA256"
+			case 3:
+				p.Algorithm = "SHA512"
+			case 4:
+				// NewTotp doesn't support MD5, but keep it so the error
+				// message will explain what's wrong
+				p.Algorithm = "MD5"
+			}
+		case field == 5 && wireType == wireVarint:
+			n, err = r.varint()
+			switch n {
+			case 1:
+				p.Digits = "6"
+			case 2:
+				p.Digits = "8"
+			}
+		case field == 6 && wireType == wireVarint:
+			n, err = r.varint()
+			if n == 1 {
+				p.Type = "hotp"
+			}
+		case field == 7 && wireType == wireVarint:
+			n, err = r.varint()
+			p.Counter = strconv.FormatUint(n, 10)
+		default:
+			err = r.skip(wireType)
+		}
+		if err != nil {
+			return
+		}
+	}
+	// Secrets are raw bytes in the payload, but Profile uses base32
+	p.Secret = base32.StdEncoding.WithPadding(base32.NoPadding).
+		EncodeToString(secret)
+	// The name is usually the same as a QR code URI label, which might have
+	// an "<issuer>:" prefix in front of the account
+	p.Account = name
+	if i := strings.Index(name, ":"); i >= 0 {
+		p.Account = strings.TrimLeft(name[i+1:], " ")
+		if p.Issuer == "" {
+			p.Issuer = name[:i]
+		}
+	}
+	// Counter only matters for HOTP
+	if p.Type != "hotp" {
+		p.Counter = ""
+	}
+	return
+}
+
+// Protobuf wire types (see https://protobuf.dev/programming-guides/encoding/)
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// protoReader is a minimal protobuf wire format reader. It only knows enough
+// to decode the otpauth-migration payload.
+type protoReader struct {
+	buf []byte
+	pos int
+}
+
+func (r *protoReader) done() bool {
+	return r.pos >= len(r.buf)
+}
+
+// varint reads a base-128 varint
+func (r *protoReader) varint() (uint64, error) {
+	var n uint64
+	for shift := 0; shift < 64; shift += 7 {
+		if r.done() {
+			return 0, errors.New("Migration data is truncated (varint)")
+		}
+		b := r.buf[r.pos]
+		r.pos++
+		n |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return n, nil
+		}
+	}
+	return 0, errors.New("Migration data is weird (varint too long)")
+}
+
+// varintInt64 reads a varint for an int32 or int64 field
+func (r *protoReader) varintInt64() (int64, error) {
+	n, err := r.varint()
+	return int64(n), err
+}
+
+// key reads a field key and splits it into field number and wire type
+func (r *protoReader) key() (field uint64, wireType int, err error) {
+	n, err := r.varint()
+	return n >> 3, int(n & 7), err
+}
+
+// bytes reads a length-delimited field
+func (r *protoReader) bytes() ([]byte, error) {
+	n, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(r.buf)-r.pos) {
+		return nil, errors.New("Migration data is truncated (length)")
+	}
+	b := r.buf[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b, nil
+}
+
+// skip skips over the value of a field with an unrecognized field number
+func (r *protoReader) skip(wireType int) error {
+	size := 0
+	switch wireType {
+	case wireVarint:
+		_, err := r.varint()
+		return err
+	case wireBytes:
+		_, err := r.bytes()
+		return err
+	case wireFixed64:
+		size = 8
+	case wireFixed32:
+		size = 4
+	default:
+		return fmt.Errorf("Migration data is weird (wire type %v)", wireType)
+	}
+	if size > len(r.buf)-r.pos {
+		return errors.New("Migration data is truncated (fixed)")
+	}
+	r.pos += size
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// These test URIs were made with a hand-written protobuf encoder. Together,
+// they make a two QR code export batch with batch_id 12345.
+var migrationURI1 string = "otpauth-migration://offline?data=" +
+	"CjIKCkhlbGxvId6tvu8SFUV4YW1wbGU6YWxpY2VAZXhhbXBsZRoHRXhhbXBsZSABKAEwAgo" +
+	"xChQxMjM0NTY3ODkwMTIzNDU2Nzg5MBIIYm9iQGFjbWUaB0FDTUUgQ28gAigCMAE4KhABGA" +
+	"IgACi5YA%3D%3D"
+var migrationURI2 string = "otpauth-migration://offline?data=" +
+	"CiUKFDEyMzQ1Njc4OTAxMjM0NTY3ODkwEgVjYXJvbBoAIAMoATACEAEYAiABKLlg"
+
+// First QR code of the batch should have a TOTP and an HOTP account
+func TestMigrationBatch1(t *testing.T) {
+	got, err := NewMigrationFromURI(migrationURI1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := Migration{
+		Profiles: []Profile{
+			{Issuer: "Example", Account: "alice@example",
+				Secret: "JBSWY3DPEHPK3PXP", Algorithm: "SHA1", Digits: "6"},
+			{Type: "hotp", Issuer: "ACME Co", Account: "bob@acme",
+				Secret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", Algorithm: "SHA256",
+				Digits: "8", Counter: "42"},
+		},
+		Version: 1, BatchSize: 2, BatchIndex: 0, BatchID: 12345,
+	}
+	if !reflect.DeepEqual(ref, got) {
+		t.Error("\nwanted:", ref, "\ngot:", got)
+	}
+}
+
+// Second QR code of the batch has a name with no issuer prefix
+func TestMigrationBatch2(t *testing.T) {
+	got, err := NewMigrationFromURI(migrationURI2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := Migration{
+		Profiles: []Profile{
+			{Account: "carol", Secret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+				Algorithm: "SHA512", Digits: "6"},
+		},
+		Version: 1, BatchSize: 2, BatchIndex: 1, BatchID: 12345,
+	}
+	if !reflect.DeepEqual(ref, got) {
+		t.Error("\nwanted:", ref, "\ngot:", got)
+	}
+}
+
+// Decoded TOTP account should produce working codes
+func TestMigrationTotp(t *testing.T) {
+	m, err := NewMigrationFromURI(migrationURI2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := m.Profiles[0]
+	totp, err := NewTotp(p.Secret, p.Digits, p.Algorithm, p.Period)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// RFC6238 SHA512 needs a 64 byte key, so this won't match Appendix B,
+	// but it should still work
+	if _, _, err := totp.CodeAtTime(59); err != nil {
+		t.Error(err)
+	}
+}
+
+// Blank data parameter should fail
+func TestMigrationEmpty(t *testing.T) {
+	got, err := NewMigrationFromURI("otpauth-migration://offline?data=")
+	if err == nil {
+		t.Error("\nwanted: error for blank data \ngot:", got)
+	}
+}
+
+// Malformed URIs and truncated or corrupt payloads should fail
+func TestMigrationMalformed(t *testing.T) {
+	for _, uri := range []string{
+		"otpauth-migration://",
+		"otpauth-migration://online?data=CgA%3D",
+		"otpauth://totp/?data=CgA%3D",
+		"otpauth-migration://offline?data=!!!",
+		// Truncated length-delimited field
+		"otpauth-migration://offline?data=CgU%3D",
+		// Truncated varint
+		"otpauth-migration://offline?data=EIA%3D",
+		// Unsupported wire type 3 (start group)
+		"otpauth-migration://offline?data=Gw%3D%3D",
+		// Batch index 2 of size 2
+		"otpauth-migration://offline?data=GAIgAg%3D%3D",
+	} {
+		if got, err := NewMigrationFromURI(uri); err == nil {
+			t.Error("\ntried:", uri, "\nwanted: error \ngot:", got)
+		}
+	}
+}
+
+// Batch sizes over the limit should fail, since a huge one would make the
+// list of missing QR codes huge too
+func TestMigrationBatchSizeLimit(t *testing.T) {
+	// Batch size 100 (the limit), index 0
+	if _, err := NewMigrationFromURI(
+		"otpauth-migration://offline?data=GGQgAA%3D%3D"); err != nil {
+		t.Error("\nwanted: batch size 100 to work\ngot:", err)
+	}
+	for _, uri := range []string{
+		// Batch size 101, index 0
+		"otpauth-migration://offline?data=GGUgAA%3D%3D",
+		// Batch size 2^62, index 0
+		"otpauth-migration://offline?data=GICAgICAgICAQCAA",
+	} {
+		got, err := NewMigrationFromURI(uri)
+		if err == nil || !strings.Contains(err.Error(), "Migration data is weird") {
+			t.Error("\ntried:", uri, "\nwanted: weird batch size error\ngot:",
+				got, err)
+		}
+	}
+}
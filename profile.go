@@ -1,21 +1,30 @@
 package main
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/url" // For QueryUnescape()
 	"regexp"
+	"strconv"
 	"strings"
 )
 
-// Profile holds the fields of a TOTP QR Code URI.
+// Profile holds the fields of a TOTP or HOTP QR Code URI. Type is blank for
+// TOTP profiles or "hotp" for HOTP profiles.
 type Profile struct {
 	URI       string `json:"URI,omitempty"`
+	Type      string `json:"type,omitempty"`
 	Issuer    string `json:"issuer,omitempty"`
 	Account   string `json:"account,omitempty"`
 	Secret    string `json:"secret,omitempty"`
 	Algorithm string `json:"algorithm,omitempty"`
 	Digits    string `json:"digits,omitempty"`
 	Period    string `json:"period,omitempty"`
+	Counter   string `json:"counter,omitempty"`
 }
 
 // String is a Stringer to make a (JSON) string representation of a Profile.
@@ -29,10 +38,56 @@ func (p Profile) String() string {
 	return strings.TrimLeft(string(bytes), " ")
 }
 
+// Masked returns a copy of the profile with the secret masked, for printing
+// where someone might be looking over your shoulder. The secret in the URI
+// field gets masked too. See maskSecret.
+func (p Profile) Masked() Profile {
+	p.Secret = maskSecret(p.Secret)
+	p.URI = uriSecretRE.ReplaceAllStringFunc(p.URI, func(m string) string {
+		return m[:len("secret=")] + maskSecret(m[len("secret="):])
+	})
+	return p
+}
+
+// uriSecretRE matches the secret parameter in a URI query
+var uriSecretRE = regexp.MustCompile(`secret=[^&]*`)
+
+// maskSecret keeps the first and last few characters of a secret, which is
+// enough to recognize it, and replaces the rest with "...". No more than 4
+// characters are shown at each end, and at least 60% of the secret is hidden.
+// The "..." is the same for every length, so it doesn't give away the length
+// either.
+func maskSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	keep := len(secret) / 5
+	if keep > 4 {
+		keep = 4
+	}
+	return secret[:keep] + "..." + secret[len(secret)-keep:]
+}
+
+// labelRE splits a URI label into issuer prefix, spaces, and account. The
+// issuer prefix runs up to the last ":" (or "%3A"), so a label like "A:B:c"
+// has the issuer "A:B" and the account "c".
+var labelRE = regexp.MustCompile(`^(?:(.*)(?::|%3[Aa])((?:%20| )*))?(.*)$`)
+
+// splitLabel splits a URI label into its issuer prefix and account, which are
+// still %-escaped. Spaces after the ":" get skipped, and come back in spaces
+// so that LintURI can report them. NewProfileFromURI and LintURI both use
+// this, so they always agree about where the issuer ends.
+func splitLabel(label string) (issuer, spaces, account string) {
+	m := labelRE.FindStringSubmatch(label)
+	return m[1], m[2], m[3]
+}
+
 // NewProfileFromURI attempts to initialize a new Profile struct from the label
-// and query parameters of a TOTP QR Code URI. The expected URI format is:
+// and query parameters of a TOTP or HOTP QR Code URI. The expected URI
+// format is:
 //
 //	otpauth://totp/<issuer>:<account>?<query-parameters>
+//	otpauth://hotp/<issuer>:<account>?<query-parameters>
 //
 // Any Profile fields that cannot be initialized from the URI input string will
 // be left blank. But, at minimum, the URI field will be set with a copy of the
@@ -46,23 +101,26 @@ func NewProfileFromURI(uri string) (p Profile) {
 	p.URI = uri
 	var issuer1, issuer2 string
 	// Remove prefix and split URI into path and query, separated by "?"
-	totpQRCodeRE := regexp.MustCompile(`^otpauth://totp/([^?]*)\?(.*)`)
-	submatches := totpQRCodeRE.FindStringSubmatch(uri)
-	if len(submatches) < 3 {
-		// URI does not match the form of otpauth://totop/<label>?<query>
+	otpQRCodeRE := regexp.MustCompile(`^otpauth://(totp|hotp)/([^?]*)\?(.*)`)
+	submatches := otpQRCodeRE.FindStringSubmatch(uri)
+	if len(submatches) < 4 {
+		// URI does not match the form of otpauth://totp/<label>?<query>
 		return
 	}
-	path := submatches[1]
+	if submatches[1] == "hotp" {
+		p.Type = "hotp"
+	}
+	path := submatches[2]
 	// Split query into key=value pairs separated by "&"
-	query := strings.Split(submatches[2], "&")
-	// Split path into ((issuer)(?:$3A|:)(?:%20)*)(account=user@domain)
-	pathRE := regexp.MustCompile(`((.*)(?:%3A|:)(?:%20)*)?(.*)`)
-	pathSubmatches := pathRE.FindStringSubmatch(path)
-	issuer1 = pathSubmatches[2]
+	query := strings.Split(submatches[3], "&")
+	// Split path into <issuer>:<account>
+	issuer1, _, p.Account = splitLabel(path)
 	if unesc, err := url.QueryUnescape(issuer1); err == nil {
 		issuer1 = unesc
 	}
-	p.Account = pathSubmatches[3]
+	if unesc, err := url.PathUnescape(p.Account); err == nil {
+		p.Account = unesc
+	}
 	// Extract query parameter values (secret=, ...)
 	for _, v := range query {
 		switch {
@@ -79,6 +137,8 @@ func NewProfileFromURI(uri string) (p Profile) {
 			p.Digits = v[len("digits="):]
 		case strings.HasPrefix(v, "period="):
 			p.Period = v[len("period="):]
+		case strings.HasPrefix(v, "counter="):
+			p.Counter = v[len("counter="):]
 		}
 	}
 	// According to this wiki in the archived google-authenticator repo,
@@ -95,3 +155,211 @@ func NewProfileFromURI(uri string) (p Profile) {
 	}
 	return
 }
+
+// ToURI makes a canonical TOTP or HOTP QR Code URI from the profile. This is
+// meant for cleaning up after scanning a weirdly formatted QR code: parse the
+// URI, fix the profile fields by hand, then get a URI back out. The format
+// follows the Key URI Format wiki page (see NewProfileFromURI):
+//   - Label is <issuer>:<account>, or just <account> if issuer is blank
+//   - Secret is uppercase base32 without "=" padding
+//   - Issuer parameter is included (matching the label prefix) when not blank
+//   - Algorithm, digits, period, and counter are included when not blank
+//   - Everything other than letters, digits, and "-._~@" gets %-escaped
+//
+// The profile's parameters must pass the same validation checks that NewTotp
+// or NewHotp would use. Otherwise, ToURI returns an error. Note that this
+// ignores the URI field, which holds the originally scanned URI.
+func (p Profile) ToURI() (string, error) {
+	if p.Type == "hotp" {
+		h, err := NewHotp(p.Secret, p.Digits, p.Algorithm, p.Counter)
+		if err != nil {
+			return "", err
+		}
+		h.Secret.Wipe()
+	} else {
+		t, err := NewTotp(p.Secret, p.Digits, p.Algorithm, p.Period)
+		if err != nil {
+			return "", err
+		}
+		t.Secret.Wipe()
+	}
+	if strings.Contains(p.Issuer, ":") || strings.Contains(p.Account, ":") {
+		return "", errors.New(
+			"Issuer and account should not contain \":\" (see Key URI Format)")
+	}
+	// Clean up the secret the same way NewTotp does before base32 decoding
+	secret, _ := url.QueryUnescape(p.Secret)
+	secret = strings.ToUpper(strings.TrimRight(secret, "="))
+	uri := "otpauth://totp/"
+	if p.Type == "hotp" {
+		uri = "otpauth://hotp/"
+	}
+	if p.Issuer != "" {
+		uri += uriEscape(p.Issuer) + ":"
+	}
+	uri += uriEscape(p.Account) + "?secret=" + secret
+	if p.Issuer != "" {
+		uri += "&issuer=" + uriEscape(p.Issuer)
+	}
+	if p.Algorithm != "" {
+		uri += "&algorithm=" + uriEscape(p.Algorithm)
+	}
+	if p.Digits != "" {
+		uri += "&digits=" + uriEscape(p.Digits)
+	}
+	if p.Type == "hotp" {
+		// The wiki says counter is required for HOTP
+		counter := p.Counter
+		if counter == "" {
+			counter = "0"
+		}
+		uri += "&counter=" + uriEscape(counter)
+	} else if p.Period != "" {
+		uri += "&period=" + uriEscape(p.Period)
+	}
+	return uri, nil
+}
+
+// Fingerprint returns a short, non-reversible summary of the profile's secret
+// and code parameters, for checking that two copies of a profile (e.g. a
+// printed backup and an enrolled account) match without showing the secret.
+// It's the first 8 bytes of a SHA-256 hash, formatted as four groups of four
+// hex digits. The hash covers the decoded secret and the validated type,
+// algorithm, digits, and period, so equivalent ways of writing a parameter
+// (e.g. "" and "SHA1") give the same fingerprint. The HOTP counter is left
+// out because it changes every time a code gets used.
+func (p Profile) Fingerprint() (string, error) {
+	var params string
+	var secret []byte
+	if p.Type == "hotp" {
+		h, err := NewHotp(p.Secret, p.Digits, p.Algorithm, p.Counter)
+		if err != nil {
+			return "", err
+		}
+		defer h.Secret.Wipe()
+		params = fmt.Sprintf("hotp %v %v\n", h.Algorithm, h.Digits)
+		secret = h.Secret
+	} else {
+		t, err := NewTotp(p.Secret, p.Digits, p.Algorithm, p.Period)
+		if err != nil {
+			return "", err
+		}
+		defer t.Secret.Wipe()
+		params = fmt.Sprintf("totp %v %v %v\n", t.Algorithm, t.Digits, t.Period)
+		secret = t.Secret
+	}
+	input := SecretBuf(append([]byte("totp-util fingerprint\n"+params),
+		secret...))
+	defer input.Wipe()
+	sum := sha256.Sum256(input)
+	h := hex.EncodeToString(sum[:8])
+	return h[0:4] + " " + h[4:8] + " " + h[8:12] + " " + h[12:16], nil
+}
+
+// FieldMatch is one line of a CompareProfiles report. Note holds the two
+// values for mismatched fields that are safe to show, or the fingerprint.
+type FieldMatch struct {
+	Field string
+	Match bool
+	Note  string
+}
+
+func (m FieldMatch) String() string {
+	status := "match   "
+	if !m.Match {
+		status = "MISMATCH"
+	}
+	if m.Note == "" {
+		return fmt.Sprintf("%v  %v", status, m.Field)
+	}
+	return fmt.Sprintf("%v  %v: %v", status, m.Field, m.Note)
+}
+
+// CompareProfiles checks whether two profiles (e.g. the current profile and a
+// scanned backup) describe the same account, field by field. Parameters get
+// compared by their validated values, so "" and "SHA1" match. Secrets get
+// compared as decoded bytes and never shown, and the last line compares the
+// overall fingerprints. The URI field and, for HOTP, the counter are not
+// compared, since they're expected to differ between copies of a profile.
+func CompareProfiles(a, b Profile) []FieldMatch {
+	matches := []FieldMatch{}
+	add := func(field, va, vb string) {
+		m := FieldMatch{Field: field, Match: va == vb}
+		if !m.Match {
+			m.Note = fmt.Sprintf("%q vs %q", va, vb)
+		}
+		matches = append(matches, m)
+	}
+	typeName := func(p Profile) string {
+		if p.Type == "hotp" {
+			return "HOTP"
+		}
+		return "TOTP"
+	}
+	add("type", typeName(a), typeName(b))
+	add("issuer", a.Issuer, b.Issuer)
+	add("account", a.Account, b.Account)
+	// Secrets
+	keyA, errA := parseSecret(a.Secret)
+	keyB, errB := parseSecret(b.Secret)
+	defer keyA.Wipe()
+	defer keyB.Wipe()
+	secretMatch := errA == nil && errB == nil &&
+		subtle.ConstantTimeCompare(keyA, keyB) == 1
+	matches = append(matches, FieldMatch{Field: "secret", Match: secretMatch})
+	// Parameters, compared as validated values when they're valid
+	normalize := func(v string, parse func(string) (string, *FieldError)) string {
+		if n, e := parse(v); e == nil {
+			return n
+		}
+		return v
+	}
+	algorithm := func(v string) (string, *FieldError) {
+		h, e := parseAlgorithm(v)
+		return h.String(), e
+	}
+	digits := func(v string) (string, *FieldError) {
+		n, e := parseDigits(v)
+		return strconv.Itoa(n), e
+	}
+	period := func(v string) (string, *FieldError) {
+		n, e := parsePeriod(v)
+		return strconv.Itoa(n), e
+	}
+	add("algorithm", normalize(a.Algorithm, algorithm),
+		normalize(b.Algorithm, algorithm))
+	add("digits", normalize(a.Digits, digits), normalize(b.Digits, digits))
+	if a.Type != "hotp" && b.Type != "hotp" {
+		add("period", normalize(a.Period, period), normalize(b.Period, period))
+	}
+	// Fingerprints
+	fa, fpErrA := a.Fingerprint()
+	fb, fpErrB := b.Fingerprint()
+	m := FieldMatch{Field: "fingerprint", Match: fpErrA == nil && fa == fb}
+	switch {
+	case fpErrA != nil || fpErrB != nil:
+		m.Note = "unavailable (unsupported parameter value)"
+	case m.Match:
+		m.Note = fa
+	default:
+		m.Note = fa + " vs " + fb
+	}
+	return append(matches, m)
+}
+
+// uriEscape %-escapes everything except RFC3986 unreserved characters and
+// "@". Unlike url.QueryEscape, spaces become "%20" rather than "+", which is
+// what the Key URI Format wiki page asks for.
+func uriEscape(s string) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~', c == '@':
+			b.WriteByte(c)
+		default:
+			b.WriteString(fmt.Sprintf("%%%02X", c))
+		}
+	}
+	return b.String()
+}
@@ -1,6 +1,9 @@
 package main
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 // Empty URI should produce empty Profile
 func TestURIEmpty(t *testing.T) {
@@ -36,7 +39,7 @@ func TestURISchemeTotp(t *testing.T) {
 
 // URI with no recognizeable fields should get stored as Profile.URI
 func TestURISchemeHotp(t *testing.T) {
-	// HOTP is not supported, but it shouldn't cause any errors at this stage
+	// This won't set the type because URI has no ? query delimiter
 	uri := "otpauth://hotp/"
 	ref := Profile{}
 	ref.URI = uri
@@ -119,6 +122,19 @@ func TestURIIssuerAccount2(t *testing.T) {
 	}
 }
 
+// The issuer prefix should run up to the last ":"
+func TestURIIssuerAccount3(t *testing.T) {
+	uri := "otpauth://totp/A:B:%20account?"
+	ref := Profile{}
+	ref.URI = uri
+	ref.Account = "account"
+	ref.Issuer = "A:B"
+	got := NewProfileFromURI(uri)
+	if ref != got {
+		t.Error("\nwanted:", ref, "\ngot:", got)
+	}
+}
+
 // This should set secret
 func TestURIQuerySecret(t *testing.T) {
 	uri := "otpauth://totp/?secret=JBSWY3DPEHPK3PXP"
@@ -322,3 +338,198 @@ func TestURIWellFormed2(t *testing.T) {
 		t.Error("\nwanted:", ref, "\ngot:", got)
 	}
 }
+
+// This should set type, issuer, account, secret, and counter
+func TestURIHotpWellFormed(t *testing.T) {
+	uri := "otpauth://hotp/Example:alice@example?" +
+		"secret=JBSWY3DPEHPK3PXP&issuer=Example&counter=42"
+	ref := Profile{}
+	ref.URI = uri
+	ref.Type = "hotp"
+	ref.Issuer = "Example"
+	ref.Account = "alice@example"
+	ref.Secret = "JBSWY3DPEHPK3PXP"
+	ref.Counter = "42"
+	got := NewProfileFromURI(uri)
+	if ref != got {
+		t.Error("\nwanted:", ref, "\ngot:", got)
+	}
+}
+
+// This should query-unescape the account
+func TestURIAccountEscaped(t *testing.T) {
+	uri := "otpauth://totp/ACME%20Co:john.doe%40acme?"
+	ref := Profile{}
+	ref.URI = uri
+	ref.Issuer = "ACME Co"
+	ref.Account = "john.doe@acme"
+	got := NewProfileFromURI(uri)
+	if ref != got {
+		t.Error("\nwanted:", ref, "\ngot:", got)
+	}
+}
+
+// Messy URIs should clean up into canonical URIs
+func TestToURI(t *testing.T) {
+	cases := []struct {
+		In   string
+		Want string
+	}{
+		{"otpauth://totp/Example:alice@example?secret=JBSWY3DPEHPK3PXP",
+			"otpauth://totp/Example:alice@example?secret=JBSWY3DPEHPK3PXP" +
+				"&issuer=Example"},
+		{"otpauth://totp/ACME%20Co:%20%20john.doe@acme?period=60&digits=8" +
+			"&secret=jbswy3dpehpk3pxpaa%3D%3D%3D%3D%3D%3D&algorithm=sha256",
+			"otpauth://totp/ACME%20Co:john.doe@acme?secret=JBSWY3DPEHPK3PXPAA" +
+				"&issuer=ACME%20Co&algorithm=SHA256&digits=8&period=60"},
+		{"otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP&issuer=A%26B%3DC%2BD",
+			"otpauth://totp/A%26B%3DC%2BD:alice?secret=JBSWY3DPEHPK3PXP" +
+				"&issuer=A%26B%3DC%2BD"},
+		{"otpauth://hotp/Example:bob?secret=JBSWY3DPEHPK3PXP&period=30",
+			"otpauth://hotp/Example:bob?secret=JBSWY3DPEHPK3PXP" +
+				"&issuer=Example&counter=0"},
+		{"otpauth://hotp/bob?counter=7&secret=JBSWY3DPEHPK3PXP",
+			"otpauth://hotp/bob?secret=JBSWY3DPEHPK3PXP&counter=7"},
+	}
+	for i, v := range cases {
+		got, err := NewProfileFromURI(v.In).ToURI()
+		if err != nil {
+			t.Error("\ni:", i, "\ngot:", err)
+		}
+		if got != v.Want {
+			t.Error("\ni:", i, "\nwanted:", v.Want, "\ngot:   ", got)
+		}
+		// Canonical URIs should round trip unchanged
+		again, err := NewProfileFromURI(got).ToURI()
+		if err != nil || again != got {
+			t.Error("\ni:", i, "\nwanted:", got, "\ngot:   ", again, err)
+		}
+	}
+}
+
+// Profiles with bad parameters should not make a URI
+func TestToURIInvalid(t *testing.T) {
+	for _, p := range []Profile{
+		{},
+		{Secret: "JBSWY3DPEHPK3PXP", Digits: "5"},
+		{Secret: "JBSWY3DPEHPK3PXP", Issuer: "a:b"},
+		{Secret: "JBSWY3DPEHPK3PXP", Type: "hotp", Counter: "-1"},
+	} {
+		if got, err := p.ToURI(); err == nil {
+			t.Error("\ntried:", p, "\nwanted: error \ngot:", got)
+		}
+	}
+}
+
+// Equivalent profiles should have the same fingerprint, and changing the
+// secret or a code parameter should change it
+func TestFingerprint(t *testing.T) {
+	base := Profile{Secret: "JBSWY3DPEHPK3PXP"}
+	want, err := base.Fingerprint()
+	if err != nil || len(want) != 19 {
+		t.Fatal("\nwanted: xxxx xxxx xxxx xxxx\ngot:", want, err)
+	}
+	for _, p := range []Profile{
+		{Secret: "jbswy3dpehpk3pxp", Algorithm: "SHA1", Digits: "6",
+			Period: "30", Issuer: "Other", Account: "someone"},
+		{Secret: "JBSWY3DPEHPK3PXP", URI: "otpauth://totp/x"},
+	} {
+		if got, err := p.Fingerprint(); err != nil || got != want {
+			t.Error("\ntried:", p, "\nwanted:", want, "\ngot:", got, err)
+		}
+	}
+	for _, p := range []Profile{
+		{Secret: "JBSWY3DPEHPK3PXQ"},
+		{Secret: "JBSWY3DPEHPK3PXP", Algorithm: "SHA256"},
+		{Secret: "JBSWY3DPEHPK3PXP", Digits: "8"},
+		{Secret: "JBSWY3DPEHPK3PXP", Period: "60"},
+		{Secret: "JBSWY3DPEHPK3PXP", Type: "hotp"},
+	} {
+		if got, err := p.Fingerprint(); err != nil || got == want {
+			t.Error("\ntried:", p, "\nwanted: not", want, "\ngot:", got, err)
+		}
+	}
+	// HOTP fingerprints shouldn't change as the counter advances
+	h0, _ := Profile{Type: "hotp", Secret: "JBSWY3DPEHPK3PXP"}.Fingerprint()
+	h9, _ := Profile{Type: "hotp", Secret: "JBSWY3DPEHPK3PXP",
+		Counter: "9"}.Fingerprint()
+	if h0 != h9 {
+		t.Error("\nwanted:", h0, "\ngot:", h9)
+	}
+	if got, err := (Profile{}).Fingerprint(); err == nil {
+		t.Error("\nwanted: error\ngot:", got)
+	}
+}
+
+// Comparisons should use validated values and never show secrets
+func TestCompareProfiles(t *testing.T) {
+	a := NewProfileFromURI("otpauth://totp/Example:alice?secret=" +
+		"JBSWY3DPEHPK3PXP&issuer=Example")
+	b := NewProfileFromURI("otpauth://totp/Example:alice?secret=" +
+		"jbswy3dpehpk3pxp&issuer=Example&algorithm=sha1&digits=6&period=30")
+	for _, m := range CompareProfiles(a, b) {
+		if !m.Match {
+			t.Error("\nwanted: match\ngot:", m)
+		}
+	}
+	b = NewProfileFromURI("otpauth://totp/Ex:alice?secret=" +
+		"JBSWY3DPEHPK3PXQ&issuer=Ex&period=60")
+	got := []string{}
+	for _, m := range CompareProfiles(a, b) {
+		got = append(got, m.String())
+	}
+	fa, _ := a.Fingerprint()
+	fb, _ := b.Fingerprint()
+	want := []string{
+		"match     type",
+		"MISMATCH  issuer: \"Example\" vs \"Ex\"",
+		"match     account",
+		"MISMATCH  secret",
+		"match     algorithm",
+		"match     digits",
+		"MISMATCH  period: \"30\" vs \"60\"",
+		"MISMATCH  fingerprint: " + fa + " vs " + fb,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Error("\nwanted:\n", strings.Join(want, "\n"), "\ngot:\n",
+			strings.Join(got, "\n"))
+	}
+	// HOTP profiles don't have a period, and invalid ones have no fingerprint
+	got = []string{}
+	for _, m := range CompareProfiles(Profile{Type: "hotp"}, Profile{}) {
+		got = append(got, m.String())
+	}
+	if g := strings.Join(got, "\n"); strings.Contains(g, "period") ||
+		!strings.Contains(g, "MISMATCH  type") ||
+		!strings.Contains(g, "MISMATCH  secret") ||
+		!strings.Contains(g, "fingerprint: unavailable") {
+		t.Error("\ngot:\n", g)
+	}
+}
+
+// Masked profiles should only show the ends of the secret, including in URI
+func TestMasked(t *testing.T) {
+	cases := map[string]string{
+		"":                                 "",
+		"ABCD":                             "...",
+		"JBSWY3DPEHPK3PXP":                 "JBS...PXP",
+		"HXDMVJECJJWSRB3HWIZR4IFUGFTMXBOZ": "HXDM...XBOZ",
+	}
+	for in, want := range cases {
+		if got := maskSecret(in); got != want {
+			t.Error("\ntried:", in, "\nwanted:", want, "\ngot:", got)
+		}
+	}
+	p := NewProfileFromURI("otpauth://totp/Example:alice?secret=" +
+		"JBSWY3DPEHPK3PXP&issuer=Example")
+	m := p.Masked()
+	if strings.Contains(m.String(), p.Secret) ||
+		!strings.Contains(m.URI, "?secret=JBS...PXP&issuer=Example") ||
+		m.Issuer != p.Issuer || m.Account != p.Account {
+		t.Error("\ngot:", m)
+	}
+	// The original should be unchanged
+	if p.Secret != "JBSWY3DPEHPK3PXP" {
+		t.Error("\nwanted: JBSWY3DPEHPK3PXP\ngot:", p.Secret)
+	}
+}
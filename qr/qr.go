@@ -0,0 +1,484 @@
+/*
+Package qr is a small QR Code encoder for putting otpauth:// URIs on screen.
+
+This started as a port of the version 1-M numeric mode encoder in
+clock/index.html. It's generalized to handle byte mode data for versions 1
+through 20 at error correction level M, which is plenty for TOTP enrollment
+URIs (version 20-M holds 666 bytes). Other modes, ECC levels, and larger
+versions are intentionally left out to keep the code short enough to audit.
+
+References are to ISO/IEC 18004:2015 unless noted otherwise.
+*/
+package qr
+
+import "fmt"
+
+// MaxVersion is the largest QR Code version supported by Encode
+const MaxVersion int = 20
+
+// blockSpec describes how a version's codewords are split into blocks for
+// error correction level M. Data gets split into group 1 blocks of Data1
+// bytes followed by group 2 blocks of Data1+1 bytes. Each block gets ECC
+// bytes of error correction (see §7.5.1 Table 9).
+type blockSpec struct {
+	ECC     int
+	Blocks1 int
+	Data1   int
+	Blocks2 int
+}
+
+// levelM holds the block structure for versions 1..20 at ECC level M. Index 0
+// is unused so that levelM[version] works.
+var levelM = [MaxVersion + 1]blockSpec{
+	{},
+	{10, 1, 16, 0},  // 1
+	{16, 1, 28, 0},  // 2
+	{26, 1, 44, 0},  // 3
+	{18, 2, 32, 0},  // 4
+	{24, 2, 43, 0},  // 5
+	{16, 4, 27, 0},  // 6
+	{18, 4, 31, 0},  // 7
+	{22, 2, 38, 2},  // 8
+	{22, 3, 36, 2},  // 9
+	{26, 4, 43, 1},  // 10
+	{30, 1, 50, 4},  // 11
+	{22, 6, 36, 2},  // 12
+	{22, 8, 37, 1},  // 13
+	{24, 4, 40, 5},  // 14
+	{24, 5, 41, 5},  // 15
+	{28, 7, 45, 3},  // 16
+	{28, 10, 46, 1}, // 17
+	{26, 9, 43, 4},  // 18
+	{26, 3, 44, 11}, // 19
+	{26, 3, 41, 13}, // 20
+}
+
+// dataCodewords returns the number of data codewords for a version
+func (b blockSpec) dataCodewords() int {
+	return b.Blocks1*b.Data1 + b.Blocks2*(b.Data1+1)
+}
+
+// alignmentCenters lists the row/column coordinates of alignment pattern
+// centers for versions 1..20 (see Annex E Table E.1)
+var alignmentCenters = [MaxVersion + 1][]int{
+	{}, {},
+	{6, 18}, {6, 22}, {6, 26}, {6, 30}, {6, 34},
+	{6, 22, 38}, {6, 24, 42}, {6, 26, 46}, {6, 28, 50}, {6, 30, 54},
+	{6, 32, 58}, {6, 34, 62},
+	{6, 26, 46, 66}, {6, 26, 48, 70}, {6, 26, 50, 74}, {6, 30, 54, 78},
+	{6, 30, 56, 82}, {6, 30, 58, 86}, {6, 34, 62, 90},
+}
+
+// Code is an encoded QR Code symbol. Modules (the barcode equivalent of
+// pixels) are addressed by (x, y) with (0, 0) at the top left. The quiet zone
+// is not included.
+type Code struct {
+	Version int
+	Size    int
+	Mask    int
+	dark    []bool
+}
+
+// Dark returns true if the module at (x, y) is dark. Coordinates outside the
+// symbol (e.g. the quiet zone) are light.
+func (c *Code) Dark(x, y int) bool {
+	if x < 0 || x >= c.Size || y < 0 || y >= c.Size {
+		return false
+	}
+	return c.dark[y*c.Size+x]
+}
+
+// Encode encodes data as a byte mode QR Code at error correction level M,
+// using the smallest version that fits.
+func Encode(data []byte) (*Code, error) {
+	// Find the smallest version that fits. The character count indicator is
+	// 8 bits for versions 1..9 or 16 bits for versions 10..26 (Table 3).
+	version := 0
+	for v := 1; v <= MaxVersion; v++ {
+		bits := 4 + countBits(v) + 8*len(data)
+		if bits <= 8*levelM[v].dataCodewords() {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, fmt.Errorf("%v bytes is too long for QR version %v-M",
+			len(data), MaxVersion)
+	}
+	codewords := encodeCodewords(data, version)
+	m := newMatrix(version)
+	m.placeCodewords(codewords)
+	// Pick the mask with the lowest penalty (§7.8.3)
+	best := -1
+	bestPenalty := 0
+	for mask := 0; mask < 8; mask++ {
+		m.applyMask(mask)
+		m.placeFormatBits(mask)
+		if p := m.penalty(); best < 0 || p < bestPenalty {
+			best = mask
+			bestPenalty = p
+		}
+		m.applyMask(mask) // Undo it (xor)
+	}
+	m.applyMask(best)
+	m.placeFormatBits(best)
+	return &Code{Version: version, Size: m.size, Mask: best, dark: m.dark}, nil
+}
+
+// countBits returns the size of the byte mode character count indicator
+func countBits(version int) int {
+	if version < 10 {
+		return 8
+	}
+	return 16
+}
+
+// bitWriter packs bit fields into bytes, most significant bit first
+type bitWriter struct {
+	buf  []byte
+	bits int
+}
+
+// write appends the low n bits of v
+func (w *bitWriter) write(v int, n int) {
+	for shift := n - 1; shift >= 0; shift-- {
+		if w.bits%8 == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		if (v>>shift)&1 == 1 {
+			w.buf[len(w.buf)-1] |= 0x80 >> (w.bits % 8)
+		}
+		w.bits++
+	}
+}
+
+// encodeCodewords builds the final sequence of interleaved data and ECC
+// codewords for the data in byte mode
+func encodeCodewords(data []byte, version int) []byte {
+	spec := levelM[version]
+	capacity := spec.dataCodewords()
+	// 1. Byte mode indicator (0b0100, 4 bits, MSB first)
+	w := bitWriter{}
+	w.write(0b0100, 4)
+	// 2. Character count indicator
+	w.write(len(data), countBits(version))
+	// 3. Data bytes
+	for _, b := range data {
+		w.write(int(b), 8)
+	}
+	// 4. Terminator sequence (0b0000, 4 bits, or abbreviate to fit)
+	if t := 8*capacity - w.bits; t > 0 {
+		if t > 4 {
+			t = 4
+		}
+		w.write(0, t)
+	}
+	// 5. Pad with 0's to a multiple of 8 bits (the bitWriter already did)
+	// 6. Add alternating pad codewords to fill the data capacity
+	codewords := w.buf
+	for i := 0; len(codewords) < capacity; i++ {
+		if i%2 == 0 {
+			codewords = append(codewords, 0b11101100)
+		} else {
+			codewords = append(codewords, 0b00010001)
+		}
+	}
+	// 7. Split data into blocks and generate ECC codewords for each block
+	var dataBlocks, eccBlocks [][]byte
+	start := 0
+	for i := 0; i < spec.Blocks1+spec.Blocks2; i++ {
+		n := spec.Data1
+		if i >= spec.Blocks1 {
+			n++
+		}
+		block := codewords[start : start+n]
+		start += n
+		dataBlocks = append(dataBlocks, block)
+		eccBlocks = append(eccBlocks, eccRemainder(block, spec.ECC))
+	}
+	// 8. Interleave the blocks (§7.6)
+	final := []byte{}
+	for i := 0; i <= spec.Data1; i++ {
+		for _, block := range dataBlocks {
+			if i < len(block) {
+				final = append(final, block[i])
+			}
+		}
+	}
+	for i := 0; i < spec.ECC; i++ {
+		for _, block := range eccBlocks {
+			final = append(final, block[i])
+		}
+	}
+	return final
+}
+
+// matrix is a staging area for drawing the modules of a symbol. The function
+// slice marks modules that belong to function patterns (finders, timing,
+// alignment, format, and version) so data placement and masking skip them.
+type matrix struct {
+	version  int
+	size     int
+	dark     []bool
+	function []bool
+}
+
+// newMatrix makes a matrix with function patterns drawn and with the format
+// and version areas reserved
+func newMatrix(version int) *matrix {
+	size := 17 + 4*version
+	m := &matrix{version: version, size: size,
+		dark: make([]bool, size*size), function: make([]bool, size*size)}
+	// Finder patterns and their light separators (§6.3.3)
+	for _, corner := range [][2]int{{0, 0}, {size - 7, 0}, {0, size - 7}} {
+		for dy := -1; dy <= 7; dy++ {
+			for dx := -1; dx <= 7; dx++ {
+				ring := max(abs(dx-3), abs(dy-3))
+				m.set(corner[0]+dx, corner[1]+dy, ring != 2 && ring != 4)
+			}
+		}
+	}
+	// Timing patterns (§6.3.5)
+	for i := 8; i < size-8; i++ {
+		m.set(i, 6, i%2 == 0)
+		m.set(6, i, i%2 == 0)
+	}
+	// Alignment patterns, except where they would overlap finders (§6.3.6)
+	centers := alignmentCenters[version]
+	last := len(centers) - 1
+	for i, cx := range centers {
+		for j, cy := range centers {
+			if (i == 0 && j == 0) || (i == 0 && j == last) ||
+				(i == last && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					m.set(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+	// Reserve format areas with a placeholder mask, and set the dark module
+	// next to the bottom-left finder pattern
+	m.placeFormatBits(0)
+	// Version information for versions 7 and up (§7.10)
+	if version >= 7 {
+		rem := version
+		for i := 0; i < 12; i++ {
+			rem = (rem << 1) ^ ((rem >> 11) * 0x1f25)
+		}
+		bits := version<<12 | rem
+		for i := 0; i < 18; i++ {
+			bit := (bits>>i)&1 == 1
+			a := size - 11 + i%3
+			b := i / 3
+			m.set(a, b, bit)
+			m.set(b, a, bit)
+		}
+	}
+	return m
+}
+
+// set sets the module at (x, y) as part of a function pattern. Coordinates
+// outside the symbol get ignored, which is convenient for finder separators.
+func (m *matrix) set(x, y int, dark bool) {
+	if x < 0 || x >= m.size || y < 0 || y >= m.size {
+		return
+	}
+	m.dark[y*m.size+x] = dark
+	m.function[y*m.size+x] = true
+}
+
+// placeFormatBits draws the 15-bit format information for ECC level M and
+// the given mask (see §7.9 "Format information")
+func (m *matrix) placeFormatBits(mask int) {
+	// The error correction level indicators are: L=0b01, M=0b00, Q=0b11,
+	// H=0b10. Calculate BCH code for generator polynomial
+	//   G(x) = x^10 + x^8 + x^5 + x^4 + x^2 + x + 1
+	// which has coefficients 0b10100110111
+	const ecLevelBits = 0b00
+	data := ecLevelBits<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0b10100110111)
+	}
+	// XOR with the mask (see Annex C, §C.2)
+	format := (data<<10 | rem) ^ 0b101010000010010
+	// First copy of format bits wraps around the top left finder pattern
+	ax := []int{8, 8, 8, 8, 8, 8, 8, 8, 7, 5, 4, 3, 2, 1, 0}
+	ay := []int{0, 1, 2, 3, 4, 5, 7, 8, 8, 8, 8, 8, 8, 8, 8}
+	s := m.size
+	for i := 0; i < 15; i++ {
+		bit := (format>>i)&1 == 1
+		m.set(ax[i], ay[i], bit)
+		// Second copy is split next to the other two finder patterns
+		if i < 8 {
+			m.set(s-1-i, 8, bit)
+		} else {
+			m.set(8, s-15+i, bit)
+		}
+	}
+	// Set the dark module next to the bottom-left finder pattern
+	m.set(8, s-8, true)
+}
+
+// placeCodewords draws the codeword bits in the zig-zag pattern of two module
+// wide columns, starting from the bottom right and skipping function patterns
+// (see §7.7.3 "Symbol character placement")
+func (m *matrix) placeCodewords(codewords []byte) {
+	i := 0
+	for right := m.size - 1; right >= 1; right -= 2 {
+		// Skip the vertical timing pattern column
+		if right == 6 {
+			right = 5
+		}
+		upward := ((right + 1) & 2) == 0
+		for vert := 0; vert < m.size; vert++ {
+			y := vert
+			if upward {
+				y = m.size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if m.function[y*m.size+x] || i >= len(codewords)*8 {
+					continue
+				}
+				m.dark[y*m.size+x] = (codewords[i/8]>>(7-i%8))&1 == 1
+				i++
+			}
+		}
+	}
+	// Any leftover modules are remainder bits, which stay light (0)
+}
+
+// applyMask xors the mask pattern onto the non-function modules. Applying the
+// same mask twice undoes it. (see §7.8.2 Table 10)
+func (m *matrix) applyMask(mask int) {
+	for y := 0; y < m.size; y++ {
+		for x := 0; x < m.size; x++ {
+			var flip bool
+			switch mask {
+			case 0:
+				flip = (x+y)%2 == 0
+			case 1:
+				flip = y%2 == 0
+			case 2:
+				flip = x%3 == 0
+			case 3:
+				flip = (x+y)%3 == 0
+			case 4:
+				flip = (x/3+y/2)%2 == 0
+			case 5:
+				flip = x*y%2+x*y%3 == 0
+			case 6:
+				flip = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				flip = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if flip && !m.function[y*m.size+x] {
+				m.dark[y*m.size+x] = !m.dark[y*m.size+x]
+			}
+		}
+	}
+}
+
+// get returns the module at (x, y), treating the quiet zone as light
+func (m *matrix) get(x, y int) bool {
+	if x < 0 || x >= m.size || y < 0 || y >= m.size {
+		return false
+	}
+	return m.dark[y*m.size+x]
+}
+
+// penalty calculates the mask penalty score according to §7.8.3.1
+// "Evaluation of QR Code symbols"
+func (m *matrix) penalty() int {
+	s := m.size
+	penalty := 0
+	// Rule 1: 5 + i adjacent modules of same color in a row or column. The
+	// closure reads rows when transpose is false or columns when it's true.
+	for _, transpose := range []bool{false, true} {
+		at := func(a, b int) bool {
+			if transpose {
+				return m.get(b, a)
+			}
+			return m.get(a, b)
+		}
+		for b := 0; b < s; b++ {
+			run := 1
+			for a := 1; a <= s; a++ {
+				if a < s && at(a, b) == at(a-1, b) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					penalty += 3 + run - 5
+				}
+				run = 1
+			}
+		}
+	}
+	// Rule 2: 2x2 blocks of same color
+	for y := 1; y < s; y++ {
+		for x := 1; x < s; x++ {
+			c := m.get(x, y)
+			if c == m.get(x-1, y) && c == m.get(x, y-1) && c == m.get(x-1, y-1) {
+				penalty += 3
+			}
+		}
+	}
+	// Rule 3: 1:1:3:1:1 finder-like pattern with 4 light modules on either
+	// side, counting the quiet zone as light
+	finder := []bool{true, false, true, true, true, false, true}
+	for _, transpose := range []bool{false, true} {
+		for b := 0; b < s; b++ {
+			for a := -4; a < s; a++ {
+				match := true
+				for k, want := range finder {
+					x, y := a+k, b
+					if transpose {
+						x, y = b, a+k
+					}
+					if m.get(x, y) != want {
+						match = false
+						break
+					}
+				}
+				if !match {
+					continue
+				}
+				lightBefore, lightAfter := true, true
+				for k := 1; k <= 4; k++ {
+					bx, by, ax, ay := a-k, b, a+6+k, b
+					if transpose {
+						bx, by, ax, ay = b, a-k, b, a+6+k
+					}
+					lightBefore = lightBefore && !m.get(bx, by)
+					lightAfter = lightAfter && !m.get(ax, ay)
+				}
+				if lightBefore || lightAfter {
+					penalty += 40
+				}
+			}
+		}
+	}
+	// Rule 4: Proportion of dark modules, 10 points for every 5% away from
+	// 50% dark
+	darkTotal := 0
+	for _, d := range m.dark {
+		if d {
+			darkTotal++
+		}
+	}
+	percent := 100 * darkTotal / (s * s)
+	penalty += 10 * (abs(percent-50) / 5)
+	return penalty
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
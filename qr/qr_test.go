@@ -0,0 +1,203 @@
+package qr
+
+import (
+	"bytes"
+	"testing"
+
+	"totp-util/internal/gf256"
+)
+
+// Generator polynomial for 10 ECC bytes, in logarithm form, should match
+// ISO/IEC 18004:2015 Annex A Table A.1 (and the table in clock/index.html)
+func TestGeneratorPoly10(t *testing.T) {
+	want := []int{0, 251, 67, 46, 61, 118, 70, 64, 94, 32, 45}
+	g := generatorPoly(10)
+	for i, c := range g {
+		if gf256.Log(c) != want[i] {
+			t.Error("\ni:", i, "\nwanted:", want[i], "\ngot:", gf256.Log(c))
+		}
+	}
+}
+
+// ECC for the ISO/IEC 18004:2015 Annex I example ("01234567" as 1-M numeric)
+func TestEccRemainderAnnexI(t *testing.T) {
+	data := []byte{
+		0x10, 0x20, 0x0c, 0x56, 0x61, 0x80, 0xec, 0x11,
+		0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11}
+	want := []byte{0xa5, 0x24, 0xd4, 0xc1, 0xed, 0x36, 0xc7, 0x87, 0x2c, 0x55}
+	got := eccRemainder(data, 10)
+	if !bytes.Equal(want, got) {
+		t.Errorf("\nwanted: %x\ngot:    %x", want, got)
+	}
+}
+
+// Codewords in the block tables should exactly fill the modules that are
+// left over after drawing the function patterns (give or take remainder bits)
+func TestCapacity(t *testing.T) {
+	for v := 1; v <= MaxVersion; v++ {
+		m := newMatrix(v)
+		free := 0
+		for _, f := range m.function {
+			if !f {
+				free++
+			}
+		}
+		spec := levelM[v]
+		total := spec.dataCodewords() + spec.ECC*(spec.Blocks1+spec.Blocks2)
+		if free/8 != total {
+			t.Error("\nversion:", v, "\nwanted:", total, "\ngot:", free/8)
+		}
+	}
+}
+
+// Encode should pick the smallest version that fits
+func TestVersionSelection(t *testing.T) {
+	cases := []struct {
+		Len     int
+		Version int
+	}{
+		{0, 1}, {14, 1}, {15, 2}, {26, 2}, {27, 3},
+		{213, 10}, {214, 11}, {666, 20},
+	}
+	for _, v := range cases {
+		c, err := Encode(make([]byte, v.Len))
+		if err != nil {
+			t.Error("\nlen:", v.Len, "\ngot:", err)
+			continue
+		}
+		if c.Version != v.Version || c.Size != 17+4*v.Version {
+			t.Error("\nlen:", v.Len, "\nwanted:", v.Version, "\ngot:", c.Version)
+		}
+	}
+	if _, err := Encode(make([]byte, 667)); err == nil {
+		t.Error("\nwanted: error for 667 bytes \ngot: nil")
+	}
+}
+
+// Format and version information should match the tables in Annex C and D
+func TestFormatAndVersionBits(t *testing.T) {
+	formatM := []int{
+		0x5412, 0x5125, 0x5e7c, 0x5b4b, 0x45f9, 0x40ce, 0x4f97, 0x4aa0}
+	ax := []int{8, 8, 8, 8, 8, 8, 8, 8, 7, 5, 4, 3, 2, 1, 0}
+	ay := []int{0, 1, 2, 3, 4, 5, 7, 8, 8, 8, 8, 8, 8, 8, 8}
+	for mask, want := range formatM {
+		m := newMatrix(1)
+		m.placeFormatBits(mask)
+		got := 0
+		for i := range ax {
+			if m.get(ax[i], ay[i]) {
+				got |= 1 << i
+			}
+		}
+		if got != want {
+			t.Errorf("\nmask: %v\nwanted: %#x\ngot:    %#x", mask, want, got)
+		}
+	}
+	versions := map[int]int{7: 0x07c94, 10: 0x0a4d3, 14: 0x0e60d, 20: 0x149a6}
+	for v, want := range versions {
+		m := newMatrix(v)
+		got := 0
+		for i := 0; i < 18; i++ {
+			if m.get(m.size-11+i%3, i/3) {
+				got |= 1 << i
+			}
+		}
+		if got != want {
+			t.Errorf("\nversion: %v\nwanted: %#x\ngot:    %#x", v, want, got)
+		}
+	}
+}
+
+// Reading the modules back in placement order, then unmasking, should give
+// codewords with valid Reed-Solomon syndromes and the original data
+func TestRoundTrip(t *testing.T) {
+	uri := "otpauth://totp/ACME%20Co:john.doe@acme?" +
+		"secret=HXDMVJECJJWSRB3HWIZR4IFUGFTMXBOZ&issuer=ACME%20Co" +
+		"&algorithm=SHA1&digits=6&period=30"
+	c, err := Encode([]byte(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Rebuild the symbol's function patterns and undo the mask
+	m := newMatrix(c.Version)
+	copy(m.dark, c.dark)
+	m.applyMask(c.Mask)
+	spec := levelM[c.Version]
+	blocks := spec.Blocks1 + spec.Blocks2
+	total := spec.dataCodewords() + spec.ECC*blocks
+	// Read codewords in the same zig-zag order as placeCodewords
+	codewords := make([]byte, total)
+	i := 0
+	for right := m.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := ((right + 1) & 2) == 0
+		for vert := 0; vert < m.size; vert++ {
+			y := vert
+			if upward {
+				y = m.size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if m.function[y*m.size+x] || i >= total*8 {
+					continue
+				}
+				if m.get(x, y) {
+					codewords[i/8] |= 0x80 >> (i % 8)
+				}
+				i++
+			}
+		}
+	}
+	// De-interleave and check syndromes: a valid block evaluates to 0 at
+	// each root of the generator polynomial
+	data := []byte{}
+	for b := 0; b < blocks; b++ {
+		n := spec.Data1
+		if b >= spec.Blocks1 {
+			n++
+		}
+		block := []byte{}
+		for k := 0; k < n; k++ {
+			// Short blocks come first, so only long blocks have byte Data1
+			idx := k * blocks
+			if k == spec.Data1 {
+				idx = spec.Data1*blocks + (b - spec.Blocks1)
+			} else {
+				idx += b
+			}
+			block = append(block, codewords[idx])
+		}
+		data = append(data, block...)
+		for k := 0; k < spec.ECC; k++ {
+			block = append(block, codewords[spec.dataCodewords()+k*blocks+b])
+		}
+		for r := 0; r < spec.ECC; r++ {
+			s := byte(0)
+			for _, cw := range block {
+				s = gf256.Mul(s, gf256.Exp(r)) ^ cw
+			}
+			if s != 0 {
+				t.Error("\nblock:", b, "root:", r, "\ngot syndrome:", s)
+			}
+		}
+	}
+	// Byte mode header: 4 bit mode, 8 or 16 bit count, then the data
+	header := 2
+	count := int(data[0]&0x0f)<<4 | int(data[1]>>4)
+	if countBits(c.Version) == 16 {
+		header = 3
+		count = int(data[0]&0x0f)<<12 | int(data[1])<<4 | int(data[2]>>4)
+	}
+	if data[0]>>4 != 0b0100 || count != len(uri) {
+		t.Fatalf("\nwanted: mode 4, count %v \ngot: %x", len(uri), data[:3])
+	}
+	got := make([]byte, count)
+	for k := range got {
+		got[k] = data[header-1+k]<<4 | data[header+k]>>4
+	}
+	if string(got) != uri {
+		t.Error("\nwanted:", uri, "\ngot:   ", string(got))
+	}
+}
@@ -0,0 +1,55 @@
+package qr
+
+// Reed-Solomon encoder using the Galois Field GF(2^8), generator 𝛼=2, prime
+// polynomial 0x11d, and generator polynomials from ISO/IEC 18004:2015 Annex
+// A. This is a port of the ReedSolomon class in clock/index.html, generalized
+// to work for any number of ECC bytes rather than only version 1-M. The field
+// arithmetic lives in internal/gf256, which the shamir package shares.
+
+import "totp-util/internal/gf256"
+
+// generatorPoly returns the coefficients (highest power first) of the
+// generator polynomial for n ECC bytes:
+//
+//	g(x) = (x - 𝛼^0) * (x - 𝛼^1) * ... * (x - 𝛼^(n-1))
+//
+// Over GF(2^8), subtraction is the same as addition (xor). The logarithms of
+// these coefficients match the tables in ISO/IEC 18004:2015 Annex A.
+func generatorPoly(n int) []byte {
+	g := []byte{1}
+	for i := 0; i < n; i++ {
+		next := make([]byte, len(g)+1)
+		copy(next, g)
+		for j := 1; j < len(next); j++ {
+			next[j] ^= gf256.Mul(g[j-1], gf256.Exp(i))
+		}
+		g = next
+	}
+	return g
+}
+
+// eccRemainder computes n Reed-Solomon ECC bytes for one block of data.
+//
+// Notes on dividing message m (data + ecc) by generator polynomial g(x):
+//  1. Leading coefficient of g(x) is always 1, so each iteration, we
+//     subtract (xor) m[i]*g(x)*x^(n-i) from m
+//  2. Unlike clock/index.html, this checks m[i] == 0 to skip terms rather
+//     than log(m[i]) == 0, because log(1) is also 0.
+func eccRemainder(data []byte, n int) []byte {
+	g := generatorPoly(n)
+	// Create dividend polynomial of data + ([0] * n)
+	m := make([]byte, len(data)+n)
+	copy(m, data)
+	// Divide by generator polynomial
+	for i := 0; i < len(data); i++ {
+		coefficient := m[i]
+		if coefficient == 0 {
+			continue
+		}
+		for j, gj := range g {
+			m[i+j] ^= gf256.Mul(gj, coefficient)
+		}
+	}
+	// Return the ECC remainder bytes
+	return m[len(data):]
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// SecretBuf holds decoded key material. Go strings can't be overwritten, but
+// byte slices can, so code that decodes a secret into a SecretBuf should Wipe
+// it as soon as it's done with it (usually with defer).
+type SecretBuf []byte
+
+// Wipe overwrites the buffer with zeros
+func (s SecretBuf) Wipe() {
+	for i := range s {
+		s[i] = 0
+	}
+	// Keep the compiler from deciding the writes are dead stores
+	runtime.KeepAlive(s)
+}
+
+// releaseMemory is for after dropping the last references to profiles (e.g.
+// clr, del, or quit). It runs the garbage collector and returns the freed
+// pages to the operating system, which on Linux means they read back as zeros
+// if they ever get reused. This doesn't overwrite anything, so it's no
+// substitute for Wipe. Freed objects that share a page with live objects stay
+// in RAM until the page gets reused, and it does nothing at all for strings
+// that are still in use.
+func releaseMemory() {
+	runtime.GC()
+	debug.FreeOSMemory()
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Wipe should zero the buffer, including through other slices of it
+func TestSecretBufWipe(t *testing.T) {
+	backing := []byte("12345678901234567890")
+	s := SecretBuf(backing)
+	s.Wipe()
+	if !bytes.Equal(backing, make([]byte, 20)) {
+		t.Error("\nwanted: zeros\ngot:", backing)
+	}
+	// Wiping nil or empty buffers should be fine
+	SecretBuf(nil).Wipe()
+}
+
+// Decoded secrets should work until wiped
+func TestTotpSecretWipe(t *testing.T) {
+	totp, err := NewTotp(key1, "8", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, _, _ := totp.CodeAtTime(59); code != "94287082" {
+		t.Error("\nwanted: 94287082\ngot:", code)
+	}
+	totp.Secret.Wipe()
+	if code, _, _ := totp.CodeAtTime(59); code == "94287082" {
+		t.Error("\nwanted: different code after wipe\ngot:", code)
+	}
+}
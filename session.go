@@ -0,0 +1,1026 @@
+package main
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"totp-util/qr"
+	"totp-util/shamir"
+	"totp-util/vault"
+)
+
+// === Types ===
+
+// Slot is one named entry in the in-memory profile list
+type Slot struct {
+	Name    string  `json:"name"`
+	Profile Profile `json:"profile"`
+}
+
+// MigrationBatch collects the accounts from a sequence of Google Authenticator
+// export QR codes that share the same batch ID
+type MigrationBatch struct {
+	ID       int64
+	Size     int64
+	Seen     map[int64]bool
+	Profiles []Profile
+}
+
+// Session holds the state of one run of the interactive menu. It reads lines
+// of input from a reader, writes everything to a writer, and gets the time
+// from a Clock, so the whole menu can be driven by a script in tests. Like
+// everything else, the profile list lives only in RAM.
+type Session struct {
+	out      io.Writer
+	clock    Clock
+	commands []Command
+	// lines delivers lines of input from the reader goroutine. It gets closed
+	// at the end of the input.
+	lines chan string
+	// setEcho turns terminal echo on or off for passphrase entry. It's nil
+	// unless the session is reading from a terminal.
+	setEcho func(on bool) error
+	quit    bool
+
+	// slots holds the profile list, and currentSlot is the index of the
+	// selected profile.
+	slots          []Slot
+	currentSlot    int
+	migrationBatch MigrationBatch
+	// shareBatch collects scanned secret shares until there are enough to
+	// restore a profile
+	shareBatch []shamir.Share
+	// maskSecrets controls whether PrintProfile masks secrets. It's on by
+	// default so secrets don't end up on screen unless asked for.
+	maskSecrets bool
+
+	// clockOffset gets added to the clock time to compensate for a
+	// workstation clock that is wrong. This is meant for airgapped computers
+	// that don't have NTP. Rather than needing root to set the system clock,
+	// you can scan a timestamp from the QR code clock (clock/index.html) to
+	// set the offset.
+	clockOffset time.Duration
+
+	// idleTimeout is how long to wait without input before clearing all the
+	// profiles and the screen, in case someone walked away from the
+	// workstation. Zero turns the idle timer off. lastInput is when the last
+	// line of input arrived, and idleCleared notes that the timer already
+	// went off, so it doesn't keep clearing the screen while nobody is there.
+	idleTimeout time.Duration
+	lastInput   time.Time
+	idleCleared bool
+}
+
+// === Global Data ===
+
+// prompt is the main menu prompt
+const prompt string = "> "
+
+// verifySkew is how many steps before or after the current TOTP step to
+// accept when checking a code with verify=<s>
+const verifySkew int = 1
+
+// defaultIdleTimeout is the idle timeout for new sessions
+const defaultIdleTimeout = 10 * time.Minute
+
+// NewSession makes a session that reads lines from in and writes to out. It
+// starts a goroutine to read lines from in so the input scanning doesn't
+// block the updating code displays.
+func NewSession(in io.Reader, out io.Writer, clock Clock) *Session {
+	s := &Session{
+		out:         out,
+		clock:       clock,
+		commands:    commands,
+		lines:       make(chan string, 100),
+		slots:       []Slot{},
+		shareBatch:  []shamir.Share{},
+		maskSecrets: true,
+		idleTimeout: defaultIdleTimeout,
+		lastInput:   clock.Now(),
+	}
+	go readLines(in, s.lines)
+	return s
+}
+
+// readLines emits lines of input read from in, then closes the channel at the
+// end of the input
+func readLines(in io.Reader, lines chan<- string) {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		lines <- scanner.Text()
+	}
+	close(lines)
+}
+
+// Run shows the menu, then handles menu choices until the user quits or the
+// input ends. It clears the profiles and shares before returning.
+func (s *Session) Run() {
+	s.ShowMenu()
+	for !s.quit {
+		fmt.Fprint(s.out, prompt)
+		s.HandleMenuChoice()
+	}
+	s.ClearProfiles()
+	s.clearShares()
+	fmt.Fprintln(s.out, "Bye")
+}
+
+// now returns the clock time adjusted by the clock offset. Code that
+// generates or verifies TOTP codes should use this.
+func (s *Session) now() time.Time {
+	return s.clock.Now().Add(s.clockOffset)
+}
+
+// CurrentProfile returns a copy of the selected profile, or an empty profile
+// if the list is empty.
+func (s *Session) CurrentProfile() Profile {
+	if len(s.slots) == 0 {
+		return Profile{}
+	}
+	return s.slots[s.currentSlot].Profile
+}
+
+// EditProfile returns a pointer to the selected profile so it can be edited in
+// place. To make manual data entry work without scanning a QR code first, this
+// adds an empty profile if the list is empty.
+func (s *Session) EditProfile() *Profile {
+	if len(s.slots) == 0 {
+		s.AddProfile(Profile{})
+	}
+	return &s.slots[s.currentSlot].Profile
+}
+
+// AddProfile appends a profile to the list and selects it. The name defaults
+// to the issuer, or the account if there is no issuer.
+func (s *Session) AddProfile(p Profile) {
+	name := p.Issuer
+	if name == "" {
+		name = p.Account
+	}
+	if name == "" {
+		name = "untitled"
+	}
+	s.slots = append(s.slots, Slot{Name: name, Profile: p})
+	s.currentSlot = len(s.slots) - 1
+}
+
+// ListProfiles prints the profile list without showing secrets. The selected
+// profile is marked with "*".
+func (s *Session) ListProfiles() {
+	if len(s.slots) == 0 {
+		fmt.Fprintln(s.out, "No profiles loaded. Try scanning a QR code.")
+		return
+	}
+	for i, slot := range s.slots {
+		mark := " "
+		if i == s.currentSlot {
+			mark = "*"
+		}
+		kind := "TOTP"
+		if slot.Profile.Type == "hotp" {
+			kind = "HOTP"
+		}
+		fmt.Fprintf(s.out, " %v%2d) %v: %v %v (%v)\n", mark, i+1, slot.Name,
+			kind, slot.Profile.Issuer, slot.Profile.Account)
+	}
+}
+
+// slotIndex converts a 1-based profile number, as shown by ListProfiles, into
+// an index for slots.
+func (s *Session) slotIndex(n string) (int, bool) {
+	i, err := strconv.Atoi(n)
+	if err != nil || i < 1 || i > len(s.slots) {
+		fmt.Fprintf(s.out, "Profile number should be in the range 1..%v.\n",
+			len(s.slots))
+		return 0, false
+	}
+	return i - 1, true
+}
+
+// SelectProfile makes profile <n> the current profile
+func (s *Session) SelectProfile(n string) {
+	if i, ok := s.slotIndex(n); ok {
+		s.currentSlot = i
+		s.ListProfiles()
+	}
+}
+
+// RenameProfile changes the name of the current profile
+func (s *Session) RenameProfile(name string) {
+	if len(s.slots) == 0 {
+		fmt.Fprintln(s.out, "No profile to rename.")
+		return
+	}
+	if name == "" {
+		fmt.Fprintln(s.out, "Name should not be empty.")
+		return
+	}
+	s.slots[s.currentSlot].Name = name
+	s.ListProfiles()
+}
+
+// DuplicateProfile appends a copy of the current profile and selects it. This
+// is handy for trying out edits without losing the original.
+func (s *Session) DuplicateProfile() {
+	if len(s.slots) == 0 {
+		fmt.Fprintln(s.out, "No profile to duplicate.")
+		return
+	}
+	slot := s.slots[s.currentSlot]
+	s.slots = append(s.slots, Slot{Name: slot.Name + " copy",
+		Profile: slot.Profile})
+	s.currentSlot = len(s.slots) - 1
+	s.ListProfiles()
+}
+
+// DeleteProfile removes the current profile from the list. The profile after
+// it (or before it, for the last one) becomes the current profile.
+func (s *Session) DeleteProfile() {
+	if len(s.slots) == 0 {
+		fmt.Fprintln(s.out, "No profile to delete.")
+		return
+	}
+	// Clear the leftover last element so the deleted profile's strings don't
+	// stay reachable from the backing array
+	copy(s.slots[s.currentSlot:], s.slots[s.currentSlot+1:])
+	s.slots[len(s.slots)-1] = Slot{}
+	s.slots = s.slots[:len(s.slots)-1]
+	if s.currentSlot >= len(s.slots) && s.currentSlot > 0 {
+		s.currentSlot--
+	}
+	releaseMemory()
+	s.ListProfiles()
+}
+
+// ClearProfiles removes all the profiles
+func (s *Session) ClearProfiles() {
+	s.slots = []Slot{}
+	s.currentSlot = 0
+	releaseMemory()
+}
+
+// ReadPassphrase prompts for a passphrase (or a secret) and reads a line of
+// input with terminal echo turned off, if possible. Like ReadAnswer, ok is
+// false if the input ended or the idle timer went off, and the caller should
+// give up on the command.
+func (s *Session) ReadPassphrase(prompt string) (line string, ok bool) {
+	fmt.Fprint(s.out, prompt)
+	if s.setEcho != nil && s.setEcho(false) == nil {
+		defer s.setEcho(true)
+	}
+	line, ok = s.ReadAnswer()
+	// After the idle timer goes off, the screen has already been cleared
+	if ok || s.quit {
+		fmt.Fprintln(s.out)
+	}
+	return line, ok
+}
+
+// SaveVault encrypts the profile list with a passphrase and writes it to
+// path. This is the only thing that writes profiles to disk, and it only
+// happens when asked. Like WriteBackup, this won't overwrite an existing file,
+// so an older vault can't get replaced by accident.
+func (s *Session) SaveVault(path string) {
+	if path == "" {
+		fmt.Fprintln(s.out, "Vault path should not be empty.")
+		return
+	}
+	if len(s.slots) == 0 {
+		fmt.Fprintln(s.out, "No profiles to save.")
+		return
+	}
+	// Check before asking for the passphrase. O_EXCL below makes sure of it.
+	if _, err := os.Lstat(path); err == nil {
+		fmt.Fprintf(s.out, "Vault file %v already exists. Vault not saved.\n",
+			path)
+		return
+	}
+	pass, ok := s.ReadPassphrase("Vault passphrase: ")
+	if !ok {
+		return
+	}
+	confirm, ok := s.ReadPassphrase("Confirm passphrase: ")
+	if !ok {
+		return
+	}
+	if confirm != pass {
+		fmt.Fprintln(s.out, "Passphrases do not match. Vault not saved.")
+		return
+	}
+	plaintext, err := json.Marshal(s.slots)
+	if err != nil {
+		fmt.Fprintln(s.out, "Unable to save vault:", err)
+		return
+	}
+	data, err := vault.Seal([]byte(pass), plaintext)
+	SecretBuf(plaintext).Wipe()
+	if err != nil {
+		fmt.Fprintln(s.out, "Unable to save vault:", err)
+		return
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		fmt.Fprintln(s.out, "Unable to save vault:", err)
+		return
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		// The file is new, so removing a partly written one loses nothing
+		os.Remove(path)
+		fmt.Fprintln(s.out, "Unable to save vault:", err)
+		return
+	}
+	fmt.Fprintf(s.out, "Saved %v profiles to %v\n", len(s.slots), path)
+}
+
+// LoadVault decrypts a vault file made by SaveVault and adds its profiles to
+// the end of the list, so loading a vault never discards unsaved profiles.
+func (s *Session) LoadVault(path string) {
+	if path == "" {
+		fmt.Fprintln(s.out, "Vault path should not be empty.")
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(s.out, "Unable to load vault:", err)
+		return
+	}
+	pass, ok := s.ReadPassphrase("Vault passphrase: ")
+	if !ok {
+		return
+	}
+	plaintext, err := vault.Open([]byte(pass), data)
+	if err != nil {
+		fmt.Fprintln(s.out, err)
+		return
+	}
+	defer SecretBuf(plaintext).Wipe()
+	loaded := []Slot{}
+	if err := json.Unmarshal(plaintext, &loaded); err != nil {
+		fmt.Fprintln(s.out, "Unable to load vault:", err)
+		return
+	}
+	if len(loaded) == 0 {
+		fmt.Fprintln(s.out, "Vault is empty.")
+		return
+	}
+	s.currentSlot = len(s.slots)
+	s.slots = append(s.slots, loaded...)
+	fmt.Fprintf(s.out, "Loaded %v profiles from %v\n", len(loaded), path)
+	s.ListProfiles()
+}
+
+// WriteBackup writes a printable backup sheet of all the profiles to path. To
+// avoid accidents, this won't overwrite an existing file.
+func (s *Session) WriteBackup(path string) {
+	if path == "" {
+		fmt.Fprintln(s.out, "Backup path should not be empty.")
+		return
+	}
+	if len(s.slots) == 0 {
+		fmt.Fprintln(s.out, "No profiles to back up.")
+		return
+	}
+	sheet, err := BackupSheet(s.slots, s.now())
+	if err != nil {
+		fmt.Fprintln(s.out, "Unable to make backup sheet:", err)
+		return
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		fmt.Fprintln(s.out, "Unable to write backup sheet:", err)
+		return
+	}
+	_, err = f.WriteString(sheet)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		fmt.Fprintln(s.out, "Unable to write backup sheet:", err)
+		return
+	}
+	fmt.Fprintf(s.out, "Wrote backup sheet for %v profiles to %v\n",
+		len(s.slots), path)
+	fmt.Fprintln(s.out, "The sheet contains secrets. Delete it after printing.")
+}
+
+// SplitProfile splits the current profile's canonical URI into n secret
+// shares, any k of which can restore the profile, and prints each share as
+// text and as a QR code. The whole URI gets split, rather than only the
+// secret, so that the restored profile has the right algorithm, digits, and
+// so on.
+func (s *Session) SplitProfile(arg string) {
+	k, n := 0, 0
+	if kStr, nStr, ok := strings.Cut(arg, ","); ok {
+		k, _ = strconv.Atoi(kStr)
+		n, _ = strconv.Atoi(nStr)
+	}
+	uri, err := s.CurrentProfile().ToURI()
+	if err != nil {
+		fmt.Fprintln(s.out, "Unable to make URI: unsupported parameter value\n",
+			err)
+		return
+	}
+	shares, err := shamir.Split([]byte(uri), k, n)
+	if err != nil {
+		fmt.Fprintln(s.out, "Unable to split profile:", err)
+		return
+	}
+	for i, share := range shares {
+		fmt.Fprintf(s.out, "\nShare %v of %v (any %v restore the profile):\n%v\n",
+			i+1, n, k, share)
+		s.PrintQR(share.String())
+	}
+}
+
+// AddShare adds a scanned share to the share batch. Once the batch has enough
+// shares, they get combined and the restored URI gets added as a new profile.
+// Scanning a share from a different split starts a new batch.
+func (s *Session) AddShare(line string) {
+	share, err := shamir.ParseShare(line)
+	if err != nil {
+		fmt.Fprintln(s.out, "Unable to read share:", err)
+		return
+	}
+	if len(s.shareBatch) > 0 && s.shareBatch[0].ID != share.ID {
+		s.clearShares()
+	}
+	for _, sh := range s.shareBatch {
+		if sh.X == share.X {
+			SecretBuf(share.Data).Wipe()
+			fmt.Fprintf(s.out, "Already have share %v.\n", share.X)
+			return
+		}
+	}
+	s.shareBatch = append(s.shareBatch, share)
+	if len(s.shareBatch) < share.Threshold {
+		fmt.Fprintf(s.out, "Added share %v (have %v of %v needed).\n", share.X,
+			len(s.shareBatch), share.Threshold)
+		return
+	}
+	secret, err := shamir.Combine(s.shareBatch)
+	s.clearShares()
+	if err != nil {
+		fmt.Fprintln(s.out, "Unable to combine shares:", err)
+		return
+	}
+	fmt.Fprintln(s.out, "Restored profile from shares.")
+	s.ParseURI(string(secret))
+	SecretBuf(secret).Wipe()
+}
+
+// clearShares wipes the collected shares, since they're key material, and
+// starts a new batch
+func (s *Session) clearShares() {
+	for _, sh := range s.shareBatch {
+		SecretBuf(sh.Data).Wipe()
+	}
+	s.shareBatch = []shamir.Share{}
+}
+
+// NoteInput resets the idle timer. Call it whenever something arrives on the
+// input channel. If ok is false, the channel was closed because the input
+// ended, so the session should quit.
+func (s *Session) NoteInput(ok bool) {
+	if !ok {
+		s.quit = true
+		return
+	}
+	s.lastInput = s.clock.Now()
+	s.idleCleared = false
+}
+
+// CheckIdle clears everything if there hasn't been any input for idleTimeout.
+// It returns true if it cleared, so that callers showing codes can stop.
+func (s *Session) CheckIdle() bool {
+	if s.idleTimeout == 0 || s.idleCleared ||
+		s.clock.Now().Sub(s.lastInput) < s.idleTimeout {
+		return false
+	}
+	s.idleCleared = true
+	s.ClearProfiles()
+	s.migrationBatch = MigrationBatch{}
+	s.clearShares()
+	// Move the cursor home, clear the screen, and clear the scrollback
+	fmt.Fprint(s.out, "\x1b[H\x1b[2J\x1b[3J")
+	fmt.Fprintf(s.out, "Cleared all profiles after %v minutes without input.\n",
+		s.idleTimeout.Minutes())
+	return true
+}
+
+// SetIdleTimeout sets the idle timer from a number of minutes
+func (s *Session) SetIdleTimeout(val string) {
+	n, err := strconv.Atoi(val)
+	if err != nil || n < 0 {
+		fmt.Fprintln(s.out,
+			"Idle timeout should be a number of minutes (0 = never).")
+		return
+	}
+	s.idleTimeout = time.Duration(n) * time.Minute
+	if n == 0 {
+		fmt.Fprintln(s.out, "Idle timer is off.")
+	} else {
+		fmt.Fprintf(s.out, "Idle timer is %v minutes.\n", n)
+	}
+}
+
+// ReadAnswer waits for a line of input in the middle of a command, checking
+// the idle timer each time the clock ticks. It returns ok=false if the input
+// ended, or if the idle timer went off, so the command doesn't carry on with
+// an answer typed after everything got cleared.
+func (s *Session) ReadAnswer() (line string, ok bool) {
+	for {
+		select {
+		case line, ok = <-s.lines:
+			s.NoteInput(ok)
+			return line, ok
+		case <-s.clock.Tick():
+			if s.CheckIdle() {
+				return "", false
+			}
+		}
+	}
+}
+
+// WaitForInput waits for a line of input at the main prompt, checking the idle
+// timer each time the clock ticks
+func (s *Session) WaitForInput() string {
+	for {
+		select {
+		case line, ok := <-s.lines:
+			s.NoteInput(ok)
+			return line
+		case <-s.clock.Tick():
+			if s.CheckIdle() {
+				fmt.Fprint(s.out, prompt)
+			}
+		}
+	}
+}
+
+// PrintProfile prints a profile along with its fingerprint (if the
+// parameters are valid) and the clock offset (if one is set). The secret gets
+// masked if maskSecrets is on.
+func (s *Session) PrintProfile(p Profile) {
+	if s.maskSecrets {
+		fmt.Fprintf(s.out, "%v\n", p.Masked())
+	} else {
+		fmt.Fprintf(s.out, "%v\n", p)
+	}
+	if fingerprint, err := p.Fingerprint(); err == nil {
+		fmt.Fprintf(s.out, "Fingerprint: %v\n", fingerprint)
+	}
+	if s.clockOffset != 0 {
+		fmt.Fprintf(s.out, "Clock offset: %+v\n", s.clockOffset)
+	}
+}
+
+// RevealProfile prints the current profile with the secret shown, regardless
+// of maskSecrets
+func (s *Session) RevealProfile() {
+	saved := s.maskSecrets
+	s.maskSecrets = false
+	s.PrintProfile(s.CurrentProfile())
+	s.maskSecrets = saved
+}
+
+// SetMasking turns secret masking for profile printouts on or off
+func (s *Session) SetMasking(val string) {
+	switch val {
+	case "on":
+		s.maskSecrets = true
+	case "off":
+		s.maskSecrets = false
+	default:
+		fmt.Fprintln(s.out, "Masking should be \"on\" or \"off\".")
+		return
+	}
+	fmt.Fprintf(s.out, "Secret masking is %v.\n", val)
+}
+
+// EnterSecret reads a secret for the current profile with echo off, so it
+// doesn't end up in the terminal scrollback the way secret=<s> does. Spaces
+// get removed, since backup sheets show secrets in groups of four. The secret
+// has to be valid base32, and it has to be confirmed, either by typing it
+// again or by checking its fingerprint (against a backup sheet, for example).
+func (s *Session) EnterSecret() {
+	secret, ok := s.ReadPassphrase("Secret (input hidden): ")
+	if !ok {
+		return
+	}
+	secret = strings.ReplaceAll(secret, " ", "")
+	key, e := parseSecret(secret)
+	defer key.Wipe()
+	if e != nil {
+		// The parser's message says where decoding failed, which would give
+		// away a little about the hidden input, so keep it generic
+		fmt.Fprintln(s.out, "Secret should be base32 (letters A-Z and digits "+
+			"2-7). Secret not changed.")
+		return
+	}
+	again, ok := s.ReadPassphrase(
+		"Type it again, or press Enter to check the fingerprint: ")
+	if !ok {
+		return
+	}
+	again = strings.ReplaceAll(again, " ", "")
+	if again != "" {
+		// Compare the decoded secrets, so differences in case or padding
+		// don't matter
+		againKey, e := parseSecret(again)
+		defer againKey.Wipe()
+		if e != nil || subtle.ConstantTimeCompare(key, againKey) != 1 {
+			fmt.Fprintln(s.out, "Secrets do not match. Secret not changed.")
+			return
+		}
+	} else {
+		p := s.CurrentProfile()
+		p.Secret = secret
+		fingerprint, err := p.Fingerprint()
+		if err != nil {
+			fmt.Fprintln(s.out, "Unable to show fingerprint:",
+				strings.TrimSpace(err.Error()))
+			fmt.Fprintln(s.out, "Secret not changed.")
+			return
+		}
+		fmt.Fprintf(s.out, "Fingerprint: %v\nDoes that match? [y/N] ",
+			fingerprint)
+		answer, ok := s.ReadAnswer()
+		if !ok {
+			return
+		}
+		if strings.TrimSpace(answer) != "y" {
+			fmt.Fprintln(s.out, "Secret not changed.")
+			return
+		}
+	}
+	s.EditProfile().Secret = secret
+	fmt.Fprintln(s.out, "Secret set.")
+}
+
+// PrintURI prints the profile as a canonical TOTP or HOTP QR Code URI.
+func (s *Session) PrintURI(p Profile) {
+	uri, err := p.ToURI()
+	if err != nil {
+		fmt.Fprintln(s.out, "Unable to make URI: unsupported parameter value\n",
+			err)
+		return
+	}
+	fmt.Fprintln(s.out, uri)
+}
+
+// ShowQR draws the profile's canonical URI as a QR code using Unicode
+// half-block characters, so each line of text holds two rows of modules.
+// This assumes light text on a dark background: light modules (including the
+// 4 module quiet zone) are drawn with block characters and dark modules are
+// left blank.
+func (s *Session) ShowQR(p Profile) {
+	uri, err := p.ToURI()
+	if err != nil {
+		fmt.Fprintln(s.out, "Unable to make URI: unsupported parameter value\n",
+			err)
+		return
+	}
+	s.PrintQR(uri)
+}
+
+// PrintQR draws text as a QR code in the same way as ShowQR
+func (s *Session) PrintQR(text string) {
+	code, err := qr.Encode([]byte(text))
+	if err != nil {
+		fmt.Fprintln(s.out, "Unable to make QR code:", err)
+		return
+	}
+	const quiet = 4
+	var b strings.Builder
+	for y := -quiet; y < code.Size+quiet; y += 2 {
+		for x := -quiet; x < code.Size+quiet; x++ {
+			top := !code.Dark(x, y)
+			bottom := !code.Dark(x, y+1) && y+1 < code.Size+quiet
+			switch {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprint(s.out, b.String())
+}
+
+// LintProfile prints a strict validation report for the URI that the profile
+// was parsed from.
+func (s *Session) LintProfile(p Profile) {
+	if p.URI == "" {
+		fmt.Fprintln(s.out, "No scanned URI to check. Try scanning a QR code.")
+		return
+	}
+	findings := LintURI(p.URI)
+	if len(findings) == 0 {
+		fmt.Fprintln(s.out, "No problems found.")
+		return
+	}
+	for _, f := range findings {
+		fmt.Fprintf(s.out, " %v\n", f)
+	}
+}
+
+// CompareURI reads a URI from the input and compares it against the current
+// profile, field by field, without showing either secret. This is for
+// checking that a backup matches the enrolled profile.
+func (s *Session) CompareURI() {
+	fmt.Fprint(s.out, "Scan URI to compare: ")
+	line, ok := s.ReadAnswer()
+	if !ok {
+		return
+	}
+	if !strings.HasPrefix(line, "otpauth://") {
+		fmt.Fprintln(s.out, "URI format not recognized.")
+		return
+	}
+	// The fingerprint only covers the secret and code parameters, so every
+	// field has to match, not just the fingerprint
+	matches := CompareProfiles(s.CurrentProfile(), NewProfileFromURI(line))
+	match := true
+	for _, m := range matches {
+		fmt.Fprintf(s.out, " %v\n", m)
+		match = match && m.Match
+	}
+	if match {
+		fmt.Fprintln(s.out, "Profiles match.")
+	} else {
+		fmt.Fprintln(s.out, "Profiles do not match.")
+	}
+}
+
+// ParseURI parses a URI in the TOTP auth app QR code URI format and adds a
+// profile to the list using its query parameters.
+func (s *Session) ParseURI(line string) {
+	p := NewProfileFromURI(line)
+	s.AddProfile(p)
+	fmt.Fprintf(s.out, "Added profile %v: %v\n", s.currentSlot+1,
+		s.slots[s.currentSlot].Name)
+	p.URI = ""
+	s.PrintProfile(p)
+}
+
+// ParseMigrationURI decodes a Google Authenticator export QR code URI and adds
+// its accounts to the current migration batch. Scanning a QR code from a
+// different export starts a new batch.
+func (s *Session) ParseMigrationURI(line string) {
+	m, err := NewMigrationFromURI(line)
+	if err != nil {
+		fmt.Fprintln(s.out, "Unable to decode export:", err)
+		return
+	}
+	b := &s.migrationBatch
+	if b.Seen == nil || b.ID != m.BatchID || b.Size != m.BatchSize {
+		*b = MigrationBatch{ID: m.BatchID, Size: m.BatchSize,
+			Seen: map[int64]bool{}}
+	}
+	if b.Seen[m.BatchIndex] {
+		fmt.Fprintf(s.out, "Already loaded QR code %v of %v.\n",
+			m.BatchIndex+1, m.BatchSize)
+		return
+	}
+	b.Seen[m.BatchIndex] = true
+	b.Profiles = append(b.Profiles, m.Profiles...)
+	fmt.Fprintf(s.out, "Loaded QR code %v of %v (%v accounts).\n",
+		m.BatchIndex+1, m.BatchSize, len(m.Profiles))
+	missing := []string{}
+	for i := int64(0); i < b.Size; i++ {
+		if !b.Seen[i] {
+			missing = append(missing, strconv.FormatInt(i+1, 10))
+		}
+	}
+	if len(missing) > 0 {
+		fmt.Fprintf(s.out, "Still need QR codes: %v\n",
+			strings.Join(missing, ", "))
+	}
+	s.ShowMigrationBatch()
+}
+
+// ShowMigrationBatch lists the accounts from the current migration batch
+// without showing their secrets.
+func (s *Session) ShowMigrationBatch() {
+	if len(s.migrationBatch.Profiles) == 0 {
+		fmt.Fprintln(s.out, "No accounts loaded. Try scanning an export QR code.")
+		return
+	}
+	for i, p := range s.migrationBatch.Profiles {
+		kind := "TOTP"
+		if p.Type == "hotp" {
+			kind = "HOTP"
+		}
+		fmt.Fprintf(s.out, " %2d) %v %v (%v)\n", i+1, kind, p.Issuer, p.Account)
+	}
+	fmt.Fprintln(s.out, "Use m=<n> to load an account into the profile.")
+}
+
+// LoadMigrationProfile copies one account from the current migration batch
+// into a new profile. Entries are numbered from 1, as shown by
+// ShowMigrationBatch.
+func (s *Session) LoadMigrationProfile(n string) {
+	i, err := strconv.Atoi(n)
+	if err != nil || i < 1 || i > len(s.migrationBatch.Profiles) {
+		fmt.Fprintf(s.out, "Account number should be in the range 1..%v.\n",
+			len(s.migrationBatch.Profiles))
+		return
+	}
+	s.AddProfile(s.migrationBatch.Profiles[i-1])
+	fmt.Fprintf(s.out, "Added profile %v: %v\n", s.currentSlot+1,
+		s.slots[s.currentSlot].Name)
+	s.PrintProfile(s.CurrentProfile())
+}
+
+// ShowTotp shows TOTP codes for the currently configured profile.
+func (s *Session) ShowTotp(p Profile) {
+	if p.Type == "hotp" {
+		fmt.Fprintln(s.out, "Profile is HOTP. Try 'h' to show HOTP code.")
+		return
+	}
+	t, err := NewTotp(p.Secret, p.Digits, p.Algorithm, p.Period)
+	if err != nil {
+		fmt.Fprintln(s.out, "Unable to show TOTP: unsupported parameter value\n",
+			err)
+		return
+	}
+	defer t.Secret.Wipe()
+	// Start a loop to display the TOTP code, updating every tick. The loop
+	// monitors the input channel and stops once a line of input is received.
+	fmt.Fprintf(s.out, "To stop displaying TOTP codes, use the Enter key.\n\n")
+	for {
+		// Generate a new code right away, then again when the clock ticks
+		if code, validSeconds, err := t.CodeAtTime(s.now().Unix()); err != nil {
+			fmt.Fprintf(s.out, "TotpCode() error: %v\n", err)
+			return
+		} else {
+			// Pad the countdown to the width of the period so the code
+			// doesn't jiggle sideways as the seconds tick down
+			width := len(strconv.Itoa(t.Period))
+			pad := strings.Repeat(" ", width-len(strconv.Itoa(validSeconds)))
+			fmt.Fprintf(s.out, "\r(%vs) %v %v  ", validSeconds, pad, code)
+		}
+		// Block until one of the channels has a message available
+		select {
+		case _, ok := <-s.lines:
+			// End the loop when Enter is pressed
+			s.NoteInput(ok)
+			fmt.Fprintln(s.out)
+			return
+		case <-s.clock.Tick():
+			// Stop if the idle timer went off
+			if s.CheckIdle() {
+				return
+			}
+		}
+	}
+}
+
+// ShowDashboard shows a full-screen view of the codes for all the profiles,
+// redrawing it every tick until a line of input is received.
+func (s *Session) ShowDashboard() {
+	// Move the cursor home and clear the screen before each frame
+	const clearScreen = "\x1b[H\x1b[2J"
+	for {
+		fmt.Fprint(s.out, clearScreen+DashboardText(s.slots, s.now().Unix()))
+		select {
+		case _, ok := <-s.lines:
+			s.NoteInput(ok)
+			fmt.Fprintln(s.out)
+			return
+		case <-s.clock.Tick():
+			if s.CheckIdle() {
+				return
+			}
+		}
+	}
+}
+
+// ShowHotp shows the HOTP code for the currently configured profile's counter.
+func (s *Session) ShowHotp(p Profile) {
+	h, err := NewHotp(p.Secret, p.Digits, p.Algorithm, p.Counter)
+	if err != nil {
+		fmt.Fprintln(s.out, "Unable to show HOTP: unsupported parameter value\n",
+			err)
+		return
+	}
+	defer h.Secret.Wipe()
+	if code, err := h.Code(); err != nil {
+		fmt.Fprintf(s.out, "HotpCode() error: %v\n", err)
+	} else {
+		fmt.Fprintf(s.out, "(counter %v) %v\n", h.Counter, code)
+	}
+}
+
+// AdvanceHotp increments the current profile's HOTP counter, then shows the
+// code for the new counter value. RFC4226 §7.2 has the token increment its
+// counter after generating a code, so this is how to keep the profile in
+// step with a token (or a validator) that has moved on.
+func (s *Session) AdvanceHotp() {
+	p := s.CurrentProfile()
+	h, err := NewHotp(p.Secret, p.Digits, p.Algorithm, p.Counter)
+	if err != nil {
+		fmt.Fprintln(s.out,
+			"Unable to advance HOTP: unsupported parameter value\n", err)
+		return
+	}
+	h.Secret.Wipe()
+	if h.Counter == math.MaxUint64 {
+		fmt.Fprintln(s.out, "Unable to advance HOTP: counter would overflow")
+		return
+	}
+	s.EditProfile().Counter = strconv.FormatUint(h.Counter+1, 10)
+	s.ShowHotp(s.CurrentProfile())
+}
+
+// VerifyTotp checks a user-entered TOTP code against the current profile and
+// reports which step, if any, it matched.
+func (s *Session) VerifyTotp(p Profile, code string) {
+	if p.Type == "hotp" {
+		fmt.Fprintln(s.out, "Profile is HOTP. Try 'h' to show HOTP code.")
+		return
+	}
+	t, err := NewTotp(p.Secret, p.Digits, p.Algorithm, p.Period)
+	if err != nil {
+		fmt.Fprintln(s.out,
+			"Unable to verify TOTP: unsupported parameter value\n", err)
+		return
+	}
+	defer t.Secret.Wipe()
+	// Allow for codes that get displayed in groups, like "123 456"
+	code = strings.ReplaceAll(code, " ", "")
+	offset, ok, err := t.VerifyAtTime(code, s.now().Unix(), verifySkew)
+	switch {
+	case err != nil:
+		fmt.Fprintf(s.out, "Verify() error: %v\n", err)
+	case !ok:
+		fmt.Fprintf(s.out, "No match (checked ±%v steps)\n", verifySkew)
+	case offset == 0:
+		fmt.Fprintln(s.out, "Match: current step")
+	default:
+		fmt.Fprintf(s.out, "Match: step %+d (%+ds)\n", offset, offset*t.Period)
+	}
+}
+
+// SetClock sets the clock offset from a QR clock timestamp and reports the
+// resulting offset.
+func (s *Session) SetClock(timestamp string) {
+	offset, err := ClockOffset(timestamp, s.clock.Now())
+	if err != nil {
+		fmt.Fprintln(s.out, "Unable to set clock offset:", err)
+		return
+	}
+	s.clockOffset = offset
+	fmt.Fprintf(s.out, "Clock offset: %+v (now %v)\n", offset,
+		s.now().UTC().Format(clockLayout))
+}
+
+// ScanURI adds a profile from a scanned TOTP or HOTP QR code URI, then starts
+// showing its codes
+func (s *Session) ScanURI(line string) {
+	switch {
+	case strings.HasPrefix(line, "otpauth://totp/"):
+		s.ParseURI(line)
+		s.ShowTotp(s.CurrentProfile())
+	case strings.HasPrefix(line, "otpauth://hotp/"):
+		s.ParseURI(line)
+		s.ShowHotp(s.CurrentProfile())
+	default:
+		fmt.Fprintln(s.out, "URI format not recognized.")
+	}
+}
+
+// HandleMenuChoice responds to inputs at the main menu prompt by looking up
+// the command in the registry and running it.
+func (s *Session) HandleMenuChoice() {
+	// Get line of input from channel connected to the reader goroutine
+	line := s.WaitForInput()
+	if line == "" {
+		return
+	}
+	c, arg, err := s.FindCommand(line)
+	if err != nil {
+		fmt.Fprintln(s.out, err)
+		return
+	}
+	c.Run(s, arg)
+}
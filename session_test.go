@@ -0,0 +1,532 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"totp-util/shamir"
+)
+
+// fakeClock is a Clock for tests. Time only moves when the test advances it,
+// and ticks only happen when the test sends them.
+type fakeClock struct {
+	mu   sync.Mutex
+	now  time.Time
+	tick chan time.Time
+}
+
+// newFakeClock makes a fake clock set to 1111111109 from RFC6238 Appendix B
+// (2005-03-18 01:58:29 UTC), which is the last second of its TOTP step
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1111111109, 0), tick: make(chan time.Time)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Tick() <-chan time.Time { return c.tick }
+
+// advance moves the clock forward by d, then sends a tick. The send blocks
+// until the session takes the tick.
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+	c.mu.Unlock()
+	c.tick <- now
+}
+
+// skip moves the clock forward by d without sending a tick
+func (c *fakeClock) skip(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// syncBuffer is a bytes.Buffer that a test can read while a session is
+// writing to it
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// waitFor waits for text to show up in the output
+func waitFor(t *testing.T, out *syncBuffer, text string) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		if strings.Contains(out.String(), text) {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("\nwanted:", text, "\ngot:\n", out.String())
+}
+
+// runSession runs a session on the fake clock with the given lines of input
+// and returns the transcript. Sessions quit at the end of the input, and no
+// ticks happen, so the codes stay put.
+func runSession(lines ...string) string {
+	var out bytes.Buffer
+	in := strings.NewReader(strings.Join(lines, "\n") + "\n")
+	NewSession(in, &out, newFakeClock()).Run()
+	return out.String()
+}
+
+// totpURI and hotpURI use the RFC test vector secret (key1)
+var totpURI = "otpauth://totp/Example:alice?secret=" + key1 + "&issuer=Example"
+var hotpURI = "otpauth://hotp/Ex:bob?secret=" + key1 + "&issuer=Ex&counter=8"
+
+// Scripted menu sessions should produce the expected output for every menu
+// command. Scanning a TOTP URI starts the code display, so those scripts
+// have an empty line after the URI to stop it.
+func TestSessionTranscripts(t *testing.T) {
+	dir := t.TempDir()
+	vaultPath := filepath.Join(dir, "profiles.vault")
+	backupPath := filepath.Join(dir, "backup.html")
+	cases := []struct {
+		Name    string
+		Input   []string
+		Want    []string
+		NotWant []string
+	}{
+		{"menu", []string{"?"},
+			[]string{" ?             - Show menu", " q             - Quit"}, nil},
+		{"print", []string{totpURI, "", "p"},
+			[]string{`"secret": "GEZD...QOJQ"`, "Fingerprint: 3ddc e4ca fff8 60b0"},
+			[]string{key1}},
+		{"print bad secret", []string{"secret=MYSECRETVALUE1", "p",
+			"otpauth://totp/X:y?secret=MYSECRETVALUE1&issuer=X", "", "p", "lint"},
+			[]string{`"secret": "MY...E1"`, "error: secret: Secret value is weird"},
+			[]string{"MYSECRETVALUE1"}},
+		{"reveal", []string{totpURI, "", "reveal", "p"},
+			[]string{`"secret": "` + key1 + `"`, `"secret": "GEZD...QOJQ"`}, nil},
+		{"mask", []string{"mask=off", totpURI, "", "p", "mask=maybe"},
+			[]string{"Secret masking is off.", `"secret": "` + key1 + `"`,
+				`Masking should be "on" or "off".`},
+			[]string{"GEZD...QOJQ"}},
+		{"uri", []string{totpURI, "", "u"}, []string{"\n> " + totpURI + "\n"}, nil},
+		{"uri invalid", []string{"digits=5", "u"},
+			[]string{"Unable to make URI: unsupported parameter value"}, nil},
+		{"qr", []string{totpURI, "", "qr"}, []string{"█▀", "▄"}, nil},
+		{"lint", []string{"lint", totpURI, "", "lint"},
+			[]string{"No scanned URI to check.", "No problems found."}, nil},
+		{"cmp match", []string{totpURI, "", "cmp", totpURI},
+			[]string{"Scan URI to compare: ", "match     secret", "Profiles match."},
+			nil},
+		{"cmp mismatch", []string{totpURI, "", "cmp",
+			strings.Replace(totpURI, "GEZD", "JBSW", 1)},
+			[]string{"MISMATCH  secret", "Profiles do not match."}, nil},
+		{"cmp issuer", []string{totpURI, "", "cmp",
+			strings.Replace(totpURI, "issuer=Example", "issuer=Other", 1)},
+			[]string{`MISMATCH  issuer: "Example" vs "Other"`,
+				"match     fingerprint: 3ddc e4ca fff8 60b0",
+				"Profiles do not match."},
+			[]string{"Profiles match."}},
+		{"totp uri", []string{totpURI, ""},
+			[]string{"Added profile 1: Example",
+				"To stop displaying TOTP codes, use the Enter key.",
+				"(1s)   081804  \n"}, nil},
+		{"hotp uri", []string{hotpURI},
+			[]string{"Added profile 1: Ex", "(counter 8) 399871"}, nil},
+		{"other uri", []string{"otpauth://foo/bar"},
+			[]string{"URI format not recognized."}, []string{"Added profile"}},
+		{"migration", []string{"m", migrationURI1, "m=2", "m=3", "m"},
+			[]string{"No accounts loaded.",
+				"Loaded QR code 1 of 2 (2 accounts).", "Still need QR codes: 2",
+				"  1) TOTP Example (alice@example)", "  2) HOTP ACME Co (bob@acme)",
+				"Added profile 1: ACME Co", "Account number should be in the range 1..2."},
+			nil},
+		{"migration bad", []string{"otpauth-migration://offline?data=%%%"},
+			[]string{"Unable to decode export:"}, nil},
+		{"edit fields", []string{"secret=" + key1, "algorithm=SHA1", "digits=8",
+			"period=30", "t", "", "u"},
+			[]string{"(1s)   07081804  \n",
+				"otpauth://totp/?secret=" + key1 + "&algorithm=SHA1&digits=8&period=30"},
+			nil},
+		{"edit bad secret", []string{"secret=MYSECRETVALUE1", "t", "", "u", "h",
+			"h+", "verify=123456", "qr", "dash", "", "split=2,3",
+			"secret=MY%ZZSECRET", "t", ""},
+			[]string{"Secret value is weird (base32 decode failed: illegal " +
+				"base32 data at input byte 13).",
+				"Secret value is weird (query unescape failed)."},
+			[]string{"MYSECRETVALUE1", "%ZZ", "ZZSECRET"}},
+		{"edit counter", []string{"secret=" + key1, "counter=1", "h", "h+", "h"},
+			[]string{"(counter 1) 287082", "(counter 2) 359152"}, nil},
+		{"secret retyped", []string{"secret", key1, strings.ToLower(key1),
+			"reveal"},
+			[]string{"Secret (input hidden): \n",
+				"Type it again, or press Enter to check the fingerprint: \n",
+				"Secret set.", `"secret": "` + key1 + `"`}, nil},
+		{"secret fingerprint", []string{"secret",
+			"GEZD GNBV GY3T QOJQ GEZD GNBV GY3T QOJQ", "", "y", "reveal"},
+			[]string{"Fingerprint: 3ddc e4ca fff8 60b0\nDoes that match? [y/N] ",
+				"Secret set.", `"secret": "` + key1 + `"`}, nil},
+		{"secret declined", []string{"secret", key1, "", "n", "p"},
+			[]string{"Does that match? [y/N] Secret not changed.\n> {}"},
+			[]string{"Secret set."}},
+		{"secret mismatch", []string{"secret", key1, "JBSWY3DPEHPK3PXP"},
+			[]string{"Secrets do not match. Secret not changed."},
+			[]string{"Secret set."}},
+		{"secret invalid", []string{"secret", "NOT-BASE32!"},
+			[]string{"Secret should be base32 (letters A-Z and digits 2-7). " +
+				"Secret not changed."},
+			[]string{"NOT-BASE32!", "Type it again"}},
+		{"clock", []string{"clock=01011200203001", "clock=2030", "clock="},
+			[]string{"Clock offset: 217330h1m32s (now 2030-01-01 12:00:01 UTC)",
+				"Unable to set clock offset: Timestamp should be 14 digits",
+				"Clock offset: 0s (now 2005-03-18 01:58:29 UTC)"}, nil},
+		{"timestamp", []string{"secret=" + key1, "digits=8", "03180158200559",
+			"p", "t", ""},
+			[]string{"Clock offset: 30s (now 2005-03-18 01:58:59 UTC)",
+				"Clock offset: 30s\n", "(1s)   14050471  \n"}, nil},
+		{"profiles", []string{"ls", totpURI, "", hotpURI, "sel=1", "name=Work",
+			"name=", "dup", "sel=2", "del", "sel=9", "ls", "clr", "ls"},
+			[]string{"No profiles loaded.", " * 1) Work: TOTP Example (alice)",
+				"Name should not be empty.",
+				" * 3) Work copy: TOTP Example (alice)",
+				"   2) Ex: HOTP Ex (bob)",
+				"Profile number should be in the range 1..2.",
+				"   1) Work: TOTP Example (alice)\n * 2) Work copy: TOTP Example (alice)"},
+			nil},
+		{"profiles empty", []string{"name=x", "dup", "del"},
+			[]string{"No profile to rename.", "No profile to duplicate.",
+				"No profile to delete."}, nil},
+		{"vault", []string{"save=" + vaultPath, totpURI, "",
+			"save=" + vaultPath, "pass", "oops",
+			"save=" + vaultPath, "pass", "pass", "clr",
+			"load=" + vaultPath, "wrong",
+			"load=" + vaultPath, "pass", "load="},
+			[]string{"No profiles to save.",
+				"Passphrases do not match. Vault not saved.",
+				"Saved 1 profiles to " + vaultPath,
+				"Unable to decrypt vault (wrong passphrase or damaged file)",
+				"Loaded 1 profiles from " + vaultPath,
+				" * 1) Example: TOTP Example (alice)",
+				"Vault path should not be empty."}, nil},
+		{"backup", []string{"backup=" + backupPath, totpURI, "",
+			"backup=" + backupPath, "backup=" + backupPath},
+			[]string{"No profiles to back up.",
+				"Wrote backup sheet for 1 profiles to " + backupPath,
+				"Unable to write backup sheet:"}, nil},
+		{"split invalid", []string{totpURI, "", "split=3,2", "TOTPSHARE1-junk"},
+			[]string{"Unable to split profile:", "Unable to read share:"}, nil},
+		{"totp hotp", []string{hotpURI, "t", "verify=123456"},
+			[]string{"Profile is HOTP. Try 'h' to show HOTP code."}, nil},
+		{"dash", []string{totpURI, "", hotpURI, "dash", ""},
+			[]string{"\x1b[H\x1b[2J", "2005-03-18 01:58:29 UTC",
+				"(press Enter key to stop)", "Example  alice    081804",
+				"counter 8"}, nil},
+		{"verify", []string{totpURI, "", "verify=081 804", "verify=050471",
+			"verify=000000"},
+			[]string{"Match: current step", "Match: step +1 (+30s)",
+				"No match (checked ±1 steps)"}, nil},
+		{"hotp", []string{hotpURI, "h", "h+", "counter=18446744073709551615", "h+"},
+			[]string{"(counter 8) 399871", "(counter 9) 520489",
+				"Unable to advance HOTP: counter would overflow"}, nil},
+		{"hotp invalid", []string{"h", "h+"},
+			[]string{"Unable to show HOTP: unsupported parameter value",
+				"Unable to advance HOTP: unsupported parameter value"}, nil},
+		{"idle", []string{"idle=5", "idle=0", "idle=-1"},
+			[]string{"Idle timer is 5 minutes.", "Idle timer is off.",
+				"Idle timeout should be a number of minutes (0 = never)."}, nil},
+		{"quit", []string{"q", "ls"}, []string{"> Bye\n"},
+			[]string{"No profiles loaded."}},
+		{"unrecognized", []string{"bogus", ""},
+			[]string{"Unrecognized input. Try '?' to show menu.\n> > Bye"}, nil},
+	}
+	for _, c := range cases {
+		got := runSession(c.Input...)
+		for _, want := range c.Want {
+			if !strings.Contains(got, want) {
+				t.Error("\ncase:", c.Name, "\nwanted:", want, "\ngot:\n", got)
+			}
+		}
+		for _, notWant := range c.NotWant {
+			if strings.Contains(got, notWant) {
+				t.Error("\ncase:", c.Name, "\ndidn't want:", notWant, "\ngot:\n", got)
+			}
+		}
+	}
+	sheet, err := os.ReadFile(backupPath)
+	if err != nil || !strings.Contains(string(sheet), "GEZD GNBV GY3T") {
+		t.Error("\nwanted: backup sheet with secret\ngot:", err)
+	}
+}
+
+// slotNames lists the profile names, with "*" on the current one
+func slotNames(s *Session) string {
+	names := []string{}
+	for i, slot := range s.slots {
+		if i == s.currentSlot {
+			names = append(names, "*"+slot.Name)
+		} else {
+			names = append(names, slot.Name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// Profile slots should add, select, rename, duplicate, delete, and clear,
+// keeping the current profile where the commands say it goes
+func TestSessionSlots(t *testing.T) {
+	out := &strings.Builder{}
+	s := NewSession(strings.NewReader(""), out, newFakeClock())
+	if p := s.CurrentProfile(); p != (Profile{}) || slotNames(s) != "" {
+		t.Fatal("\nwanted: no profiles\ngot:", p, slotNames(s))
+	}
+	s.AddProfile(Profile{Issuer: "A"})
+	s.AddProfile(Profile{Account: "b"})
+	s.AddProfile(Profile{})
+	steps := []struct {
+		Do   func()
+		Want string
+	}{
+		{func() {}, "A,b,*untitled"},
+		{func() { s.SelectProfile("1") }, "*A,b,untitled"},
+		{func() { s.SelectProfile("0") }, "*A,b,untitled"},
+		{func() { s.SelectProfile("4") }, "*A,b,untitled"},
+		{func() { s.SelectProfile("x") }, "*A,b,untitled"},
+		{func() { s.SelectProfile("3") }, "A,b,*untitled"},
+		{func() { s.RenameProfile("C") }, "A,b,*C"},
+		{func() { s.RenameProfile("") }, "A,b,*C"},
+		{func() { s.SelectProfile("2"); s.DuplicateProfile() }, "A,b,C,*b copy"},
+		// Deleting the last profile selects the one before it
+		{func() { s.DeleteProfile() }, "A,b,*C"},
+		// Deleting another profile selects the one after it
+		{func() { s.SelectProfile("1"); s.DeleteProfile() }, "*b,C"},
+		{func() { s.SelectProfile("2"); s.DeleteProfile() }, "*b"},
+		{func() { s.DeleteProfile() }, ""},
+		{func() { s.DeleteProfile() }, ""},
+		{func() { s.AddProfile(Profile{}); s.ClearProfiles() }, ""},
+	}
+	for i, step := range steps {
+		step.Do()
+		if got := slotNames(s); got != step.Want {
+			t.Error("\nstep:", i, "\nwanted:", step.Want, "\ngot:", got)
+		}
+	}
+	if s.currentSlot != 0 {
+		t.Error("\nwanted: current slot 0 after clr\ngot:", s.currentSlot)
+	}
+	for _, want := range []string{
+		"Profile number should be in the range 1..3.",
+		"Name should not be empty.", "No profile to delete.",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Error("\nwanted:", want, "\ngot:\n", out.String())
+		}
+	}
+}
+
+// A saved vault should load in a new session, and saving again to the same
+// path should leave the first vault alone
+func TestSessionVault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profiles.vault")
+	got := runSession(totpURI, "", "save="+path, "pass", "pass", "name=Other",
+		"save="+path)
+	saved, err := os.ReadFile(path)
+	if err != nil || !strings.Contains(got, "Saved 1 profiles to "+path) ||
+		!strings.Contains(got, "Vault file "+path+" already exists. Vault not saved.") {
+		t.Fatal("\nwanted: one save, then a refusal\ngot:\n", got, err)
+	}
+	// The refusal should come before the passphrase prompt
+	if strings.Count(got, "Vault passphrase: ") != 1 {
+		t.Error("\nwanted: one passphrase prompt\ngot:\n", got)
+	}
+	got = runSession("load="+path, "pass", "u")
+	if !strings.Contains(got, "Loaded 1 profiles from "+path) ||
+		!strings.Contains(got, " * 1) Example: TOTP Example (alice)") ||
+		!strings.Contains(got, "> "+totpURI) {
+		t.Error("\nwanted: profile loaded from vault\ngot:\n", got)
+	}
+	if again, _ := os.ReadFile(path); !bytes.Equal(again, saved) {
+		t.Error("\nwanted: vault file unchanged")
+	}
+}
+
+// Shares printed by split should restore the profile in a new session
+func TestSessionSplitShares(t *testing.T) {
+	got := runSession(totpURI, "", "split=2,3")
+	shares := regexp.MustCompile(`(?m)^TOTPSHARE1-\S+$`).FindAllString(got, -1)
+	if len(shares) != 3 || !strings.Contains(got, "Share 3 of 3 (any 2 restore") {
+		t.Fatal("\nwanted: 3 shares\ngot:\n", got)
+	}
+	got = runSession(shares[2], shares[2], shares[0], "u")
+	for _, want := range []string{
+		"Added share 3 (have 1 of 2 needed).", "Already have share 3.",
+		"Restored profile from shares.", "Added profile 1: Example",
+		"> " + totpURI,
+	} {
+		if !strings.Contains(got, want) {
+			t.Error("\nwanted:", want, "\ngot:\n", got)
+		}
+	}
+}
+
+// Shares should get wiped when a share from a different split replaces them
+func TestSessionShareWipe(t *testing.T) {
+	s := NewSession(strings.NewReader(""), &strings.Builder{}, newFakeClock())
+	first, _ := shamir.Split([]byte(totpURI), 2, 2)
+	second, _ := shamir.Split([]byte(totpURI), 2, 2)
+	s.AddShare(first[0].String())
+	kept := s.shareBatch[0].Data
+	s.AddShare(second[0].String())
+	if len(s.shareBatch) != 1 || s.shareBatch[0].ID != second[0].ID {
+		t.Fatal("\nwanted: new batch\ngot:", s.shareBatch)
+	}
+	if !bytes.Equal(kept, make([]byte, len(kept))) {
+		t.Error("\nwanted: old share wiped\ngot:", kept)
+	}
+}
+
+// The idle timer should clear everything once, and only once, when the clock
+// passes the timeout while waiting at the prompt
+func TestSessionIdleAtPrompt(t *testing.T) {
+	clock := newFakeClock()
+	in, input := io.Pipe()
+	out := &syncBuffer{}
+	s := NewSession(in, out, clock)
+	s.AddProfile(NewProfileFromURI(totpURI))
+	done := make(chan bool)
+	go func() { s.Run(); close(done) }()
+	clock.advance(9 * time.Minute)
+	clock.advance(2 * time.Minute)
+	clock.advance(time.Hour)
+	io.WriteString(input, "ls\n")
+	input.Close()
+	<-done
+	got := out.String()
+	want := "\x1b[H\x1b[2J\x1b[3JCleared all profiles after 10 minutes without " +
+		"input.\n> No profiles loaded."
+	if !strings.Contains(got, want) || strings.Count(got, "Cleared") != 1 {
+		t.Error("\nwanted:", want, "\ngot:\n", got)
+	}
+}
+
+// The idle timer should go off once after the timeout, start over after
+// input, and follow idle=<n>
+func TestSessionCheckIdle(t *testing.T) {
+	clock := newFakeClock()
+	out := &strings.Builder{}
+	s := NewSession(strings.NewReader(""), out, clock)
+	s.AddProfile(NewProfileFromURI(totpURI))
+	clock.skip(9 * time.Minute)
+	if s.CheckIdle() || len(s.slots) != 1 {
+		t.Fatal("\nwanted: no clear before 10 minutes\ngot:\n", out.String())
+	}
+	clock.skip(time.Minute)
+	if !s.CheckIdle() || len(s.slots) != 0 || !strings.Contains(out.String(),
+		"Cleared all profiles after 10 minutes without input.") {
+		t.Fatal("\nwanted: clear at 10 minutes\ngot:\n", out.String())
+	}
+	if s.CheckIdle() {
+		t.Error("\nwanted: only one clear until there's input")
+	}
+	s.NoteInput(true)
+	s.SetIdleTimeout("1")
+	s.AddProfile(NewProfileFromURI(totpURI))
+	clock.skip(59 * time.Second)
+	if s.CheckIdle() {
+		t.Error("\nwanted: no clear before 1 minute")
+	}
+	clock.skip(time.Second)
+	if !s.CheckIdle() || len(s.slots) != 0 {
+		t.Error("\nwanted: clear at 1 minute")
+	}
+	s.NoteInput(true)
+	s.SetIdleTimeout("-1")
+	s.SetIdleTimeout("x")
+	s.SetIdleTimeout("0")
+	clock.skip(24 * time.Hour)
+	if s.CheckIdle() {
+		t.Error("\nwanted: no clear with the timer off")
+	}
+	for _, want := range []string{"Idle timer is 1 minutes.",
+		"Idle timeout should be a number of minutes (0 = never).",
+		"Idle timer is off."} {
+		if !strings.Contains(out.String(), want) {
+			t.Error("\nwanted:", want, "\ngot:\n", out.String())
+		}
+	}
+}
+
+// The idle timer should also go off at passphrase and secret prompts, and
+// the command should give up rather than use a line typed afterwards
+func TestSessionIdleAtPassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profiles.vault")
+	clock := newFakeClock()
+	in, input := io.Pipe()
+	out := &syncBuffer{}
+	s := NewSession(in, out, clock)
+	s.AddProfile(NewProfileFromURI(totpURI))
+	done := make(chan bool)
+	go func() { s.Run(); close(done) }()
+	io.WriteString(input, "save="+path+"\n")
+	waitFor(t, out, "Vault passphrase: ")
+	clock.advance(11 * time.Minute)
+	waitFor(t, out, "Cleared all profiles after 10 minutes without input.")
+	io.WriteString(input, "pass\n")
+	io.WriteString(input, "secret\n")
+	waitFor(t, out, "Secret (input hidden): ")
+	clock.advance(11 * time.Minute)
+	io.WriteString(input, key1+"\n")
+	input.Close()
+	<-done
+	got := out.String()
+	if strings.Contains(got, "Confirm passphrase") ||
+		strings.Contains(got, "Type it again") ||
+		strings.Count(got, "Cleared all profiles") != 2 {
+		t.Error("\nwanted: both commands stopped by the idle timer\ngot:\n", got)
+	}
+	if _, err := os.Stat(path); err == nil {
+		t.Error("\nwanted: no vault file")
+	}
+	if len(s.slots) != 0 {
+		t.Error("\nwanted: no profiles\ngot:", s.slots)
+	}
+}
+
+// The TOTP display should redraw on each tick and stop when the idle timer
+// goes off
+func TestSessionTicks(t *testing.T) {
+	clock := newFakeClock()
+	in, input := io.Pipe()
+	out := &syncBuffer{}
+	s := NewSession(in, out, clock)
+	done := make(chan bool)
+	go func() { s.Run(); close(done) }()
+	io.WriteString(input, totpURI+"\n")
+	waitFor(t, out, "(1s)   081804")
+	clock.advance(time.Second)
+	waitFor(t, out, "(30s)  050471")
+	clock.advance(11 * time.Minute)
+	waitFor(t, out, "Cleared all profiles after 10 minutes without input.")
+	io.WriteString(input, "ls\n")
+	input.Close()
+	<-done
+	if got := out.String(); !strings.Contains(got, "> No profiles loaded.") {
+		t.Error("\nwanted: No profiles loaded.\ngot:\n", got)
+	}
+}
@@ -0,0 +1,179 @@
+/*
+Package shamir splits a secret into k-of-n shares using Shamir's secret
+sharing over GF(2^8).
+
+Each byte of the secret is the constant term of its own random polynomial of
+degree k-1, and share x holds the value of each polynomial at x. Any k shares
+pin down the polynomials, so Lagrange interpolation at x=0 gets the secret
+back. Fewer than k shares say nothing about the secret.
+
+The field is the same one used for QR code Reed-Solomon (generator 𝛼=2, prime
+polynomial 0x11d), and the arithmetic for it comes from internal/gf256.
+
+Before splitting, Split appends the first 4 bytes of the secret's SHA-256
+hash. Combine checks them, which catches typos and mixed up shares that would
+otherwise produce garbage without complaint. Since the checksum is split along
+with the secret, it doesn't leak anything to someone holding too few shares.
+*/
+package shamir
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"totp-util/internal/gf256"
+)
+
+// Share is one piece of a split secret. Shares from the same call to Split
+// have the same ID and Threshold, and different X values.
+type Share struct {
+	ID        uint32
+	Threshold int
+	X         byte
+	Data      []byte
+}
+
+const checksumLen = 4
+
+// Split splits secret into n shares such that any k of them can recover it.
+// The limits are 2 <= k <= n <= 255 (x=0 is where the secret lives, so
+// there are only 255 places to put shares).
+func Split(secret []byte, k, n int) ([]Share, error) {
+	if k < 2 || k > n || n > 255 {
+		return nil, errors.New("Share counts should be 2 <= k <= n <= 255")
+	}
+	if len(secret) == 0 {
+		return nil, errors.New("Secret should not be empty")
+	}
+	sum := sha256.Sum256(secret)
+	payload := append(append([]byte{}, secret...), sum[:checksumLen]...)
+	// Random ID and polynomial coefficients. coef[i] holds the coefficients
+	// for x^1..x^(k-1) of the polynomial for payload byte i.
+	random := make([]byte, 4+len(payload)*(k-1))
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	id := binary.BigEndian.Uint32(random)
+	coef := random[4:]
+	shares := make([]Share, n)
+	for s := range shares {
+		x := byte(s + 1)
+		data := make([]byte, len(payload))
+		for i, b := range payload {
+			// Horner's method, highest power first
+			y := byte(0)
+			c := coef[i*(k-1) : (i+1)*(k-1)]
+			for j := len(c) - 1; j >= 0; j-- {
+				y = gf256.Mul(y, x) ^ c[j]
+			}
+			data[i] = gf256.Mul(y, x) ^ b
+		}
+		shares[s] = Share{ID: id, Threshold: k, X: x, Data: data}
+	}
+	return shares, nil
+}
+
+// Combine recovers a secret from at least Threshold shares made by Split.
+// Extra shares beyond the threshold are ignored.
+func Combine(shares []Share) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, errors.New("No shares to combine")
+	}
+	first := shares[0]
+	if len(shares) < first.Threshold {
+		return nil, fmt.Errorf("Need %v shares but only have %v",
+			first.Threshold, len(shares))
+	}
+	shares = shares[:first.Threshold]
+	seen := map[byte]bool{}
+	for _, s := range shares {
+		switch {
+		case s.ID != first.ID || s.Threshold != first.Threshold:
+			return nil, errors.New("Shares are from different splits")
+		case len(s.Data) != len(first.Data):
+			return nil, errors.New("Shares have different lengths")
+		case s.X == 0 || seen[s.X]:
+			return nil, fmt.Errorf("Share number %v is invalid or repeated", s.X)
+		}
+		seen[s.X] = true
+	}
+	if len(first.Data) <= checksumLen {
+		return nil, errors.New("Shares are too short")
+	}
+	// Lagrange interpolation at x=0. Over GF(2^8), subtraction is xor, so
+	// the basis polynomial for share i at 0 is the product of
+	// x_j / (x_j ^ x_i) over the other shares j.
+	payload := make([]byte, len(first.Data))
+	for i, si := range shares {
+		basis := byte(1)
+		for j, sj := range shares {
+			if i != j {
+				basis = gf256.Mul(basis, gf256.Div(sj.X, sj.X^si.X))
+			}
+		}
+		for b := range payload {
+			payload[b] ^= gf256.Mul(basis, si.Data[b])
+		}
+	}
+	secret := payload[:len(payload)-checksumLen]
+	sum := sha256.Sum256(secret)
+	if !bytes.Equal(sum[:checksumLen], payload[len(secret):]) {
+		return nil, errors.New(
+			"Combined secret failed checksum (damaged or mismatched shares)")
+	}
+	return secret, nil
+}
+
+// shareEncoding is uppercase base32 without padding, which is easy to read
+// aloud and type back in
+var shareEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// shareRE matches the text format made by Share.String
+var shareRE = regexp.MustCompile(
+	`^TOTPSHARE1-([0-9A-F]{8})-([0-9]{1,3})-([0-9]{1,3})-([A-Z2-7]+)$`)
+
+// String formats a share as text for printing or putting in a QR code:
+//
+//	TOTPSHARE1-<id>-<threshold>-<x>-<base32 data>
+//
+// where <id> is 8 hex digits, and <threshold> and <x> are decimal.
+func (s Share) String() string {
+	return fmt.Sprintf("TOTPSHARE1-%08X-%v-%v-%v", s.ID, s.Threshold, s.X,
+		shareEncoding.EncodeToString(s.Data))
+}
+
+// IsShare reports whether text looks like a share, so that callers can tell
+// shares apart from other input before parsing them
+func IsShare(text string) bool {
+	return strings.HasPrefix(strings.ToUpper(text), "TOTPSHARE1-")
+}
+
+// ParseShare parses the text format made by Share.String. Lowercase is okay.
+func ParseShare(text string) (s Share, err error) {
+	m := shareRE.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(text)))
+	if m == nil {
+		err = errors.New("Share should look like TOTPSHARE1-<id>-<k>-<x>-<data>")
+		return
+	}
+	id, _ := strconv.ParseUint(m[1], 16, 32)
+	k, _ := strconv.Atoi(m[2])
+	x, _ := strconv.Atoi(m[3])
+	if k < 2 || x < 1 || x > 255 {
+		err = errors.New("Share threshold or number is out of range")
+		return
+	}
+	data, e := shareEncoding.DecodeString(m[4])
+	if e != nil {
+		err = fmt.Errorf("Share data is weird (base32 decode failed: %v)", e)
+		return
+	}
+	return Share{ID: uint32(id), Threshold: k, X: byte(x), Data: data}, nil
+}
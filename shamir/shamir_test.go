@@ -0,0 +1,82 @@
+package shamir
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Every k-share subset of a 3-of-5 split should recover the secret
+func TestSplitCombine(t *testing.T) {
+	secret := []byte("otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP")
+	shares, err := Split(secret, 3, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for a := 0; a < 5; a++ {
+		for b := a + 1; b < 5; b++ {
+			for c := b + 1; c < 5; c++ {
+				// Mix up the order too
+				got, err := Combine([]Share{shares[c], shares[a], shares[b]})
+				if err != nil || !bytes.Equal(got, secret) {
+					t.Error("\ntried:", a, b, c, "\ngot:", string(got), err)
+				}
+			}
+		}
+	}
+	if _, err := Combine(shares[:2]); err == nil {
+		t.Error("\ntoo few shares\nwanted: error\ngot: nil")
+	}
+	if _, err := Combine([]Share{shares[0], shares[0], shares[1]}); err == nil {
+		t.Error("\nrepeated share\nwanted: error\ngot: nil")
+	}
+	// Damaged data should fail the checksum rather than give garbage
+	bad := shares[2]
+	bad.Data = append([]byte{}, bad.Data...)
+	bad.Data[0] ^= 1
+	if _, err := Combine([]Share{shares[0], shares[1], bad}); err == nil {
+		t.Error("\ndamaged share\nwanted: error\ngot: nil")
+	}
+	// Shares from a different split of the same secret shouldn't mix
+	other, _ := Split(secret, 3, 5)
+	if _, err := Combine([]Share{shares[0], shares[1], other[2]}); err == nil {
+		t.Error("\nmixed splits\nwanted: error\ngot: nil")
+	}
+}
+
+// Bad share counts should be rejected
+func TestSplitLimits(t *testing.T) {
+	for _, c := range [][2]int{{1, 3}, {4, 3}, {2, 256}, {0, 0}} {
+		if _, err := Split([]byte("x"), c[0], c[1]); err == nil {
+			t.Error("\ntried:", c, "\nwanted: error\ngot: nil")
+		}
+	}
+	if _, err := Split(nil, 2, 3); err == nil {
+		t.Error("\nempty secret\nwanted: error\ngot: nil")
+	}
+}
+
+// Shares should round trip through their text format
+func TestShareText(t *testing.T) {
+	s := Share{ID: 0x0badcafe, Threshold: 2, X: 17, Data: []byte("hello")}
+	text := s.String()
+	if want := "TOTPSHARE1-0BADCAFE-2-17-NBSWY3DP"; text != want {
+		t.Error("\nwanted:", want, "\ngot:", text)
+	}
+	got, err := ParseShare(" totpshare1-0badcafe-2-17-nbswy3dp ")
+	if err != nil || got.ID != s.ID || got.Threshold != s.Threshold ||
+		got.X != s.X || !bytes.Equal(got.Data, s.Data) {
+		t.Error("\nwanted:", s, "\ngot:", got, err)
+	}
+	if !IsShare("totpshare1-") || IsShare("otpauth://") {
+		t.Error("IsShare is confused")
+	}
+	for _, bad := range []string{
+		"", "TOTPSHARE1-0BADCAFE-2-17-", "TOTPSHARE1-0BADCAFE-1-17-NBSWY3DP",
+		"TOTPSHARE1-0BADCAFE-2-0-NBSWY3DP", "TOTPSHARE1-0BADCAFE-2-256-NBSWY3DP",
+		"TOTPSHARE1-0BADCAFE-2-17-NBSW8", "TOTPSHARE1-0BADCAF-2-17-NBSWY3DP",
+	} {
+		if _, err := ParseShare(bad); err == nil {
+			t.Error("\ntried:", bad, "\nwanted: error\ngot: nil")
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// setEcho turns terminal echo for stdin on or off using the termios ioctls.
+// If stdin isn't a terminal (e.g. input piped from a file), this returns an
+// error and changes nothing.
+func setEcho(on bool) error {
+	var t syscall.Termios
+	if err := termios(syscall.TCGETS, &t); err != nil {
+		return err
+	}
+	if on {
+		t.Lflag |= syscall.ECHO
+	} else {
+		t.Lflag &^= syscall.ECHO
+	}
+	return termios(syscall.TCSETS, &t)
+}
+
+// makeRaw puts the terminal on stdin into raw mode for the line editor: no
+// echo, no line buffering, and no signals from control keys, so Ctrl-C goes
+// to the line editor (which ends the session cleanly). Output processing
+// stays on so "\n" still starts a new line. It returns a function that puts
+// the old settings back. If stdin isn't a terminal, this returns an error and
+// changes nothing.
+func makeRaw() (restore func() error, err error) {
+	var saved syscall.Termios
+	if err := termios(syscall.TCGETS, &saved); err != nil {
+		return nil, err
+	}
+	raw := saved
+	raw.Iflag &^= syscall.ICRNL | syscall.INLCR | syscall.IGNCR | syscall.IXON
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON |
+		syscall.ISIG | syscall.IEXTEN
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := termios(syscall.TCSETS, &raw); err != nil {
+		return nil, err
+	}
+	return func() error { return termios(syscall.TCSETS, &saved) }, nil
+}
+
+// termios gets or sets the terminal attributes for stdin
+func termios(request uintptr, t *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(syscall.Stdin),
+		request, uintptr(unsafe.Pointer(t)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// setEcho is only implemented for Linux
+func setEcho(on bool) error {
+	return errors.New("turning off terminal echo is only supported on Linux")
+}
+
+// makeRaw is only implemented for Linux
+func makeRaw() (restore func() error, err error) {
+	return nil, errors.New("terminal raw mode is only supported on Linux")
+}
@@ -5,21 +5,30 @@ import base64
 
 k1_int     = 0x3132333435363738393031323334353637383930
 k256_int   = 0x3132333435363738393031323334353637383930313233343536373839303132
+k512_int   = int.from_bytes(b'1234567890' * 6 + b'1234', byteorder='big')
 k1_ascii   = k1_int.to_bytes(length=20, byteorder='big')
 k256_ascii = k256_int.to_bytes(length=32, byteorder='big')
+k512_ascii = k512_int.to_bytes(length=64, byteorder='big')
 k1_b32     = base64.b32encode(k1_ascii)
 k256_b32   = base64.b32encode(k256_ascii)
+k512_b32   = base64.b32encode(k512_ascii)
 
 print("k1_int:    ", hex(k1_int))
 print("k256_int:  ", hex(k256_int))
+print("k512_int:  ", hex(k512_int))
 print("k1_ascii:  ", k1_ascii)
 print("k256_ascii:", k256_ascii)
+print("k512_ascii:", k512_ascii)
 print("k1_b32:    ", k1_b32)
 print("k256_b32:  ", k256_b32)
+print("k512_b32:  ", k512_b32)
 
 # k1_int:     0x3132333435363738393031323334353637383930
 # k256_int:   0x3132333435363738393031323334353637383930313233343536373839303132
+# k512_int:   0x31323334353637383930313233343536373839303132333435363738393031323334353637383930313233343536373839303132333435363738393031323334
 # k1_ascii:   b'12345678901234567890'
 # k256_ascii: b'12345678901234567890123456789012'
+# k512_ascii: b'1234567890123456789012345678901234567890123456789012345678901234'
 # k1_b32:     b'GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ'
 # k256_b32:   b'GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA===='
+# k512_b32:   b'GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNA='
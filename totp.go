@@ -4,14 +4,16 @@ import (
 	"crypto/hmac"
 	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
 	"encoding/base32"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"hash"
 	"net/url" // for QueryUnescape()
+	"strconv"
 	"strings"
-	"time"
 )
 
 // HmacAlgo is an enum representing supported HMAC hash algorithms
@@ -20,6 +22,7 @@ type HmacAlgo int
 const (
 	HmacSha1 HmacAlgo = iota
 	HmacSha256
+	HmacSha512
 )
 
 func (h HmacAlgo) String() string {
@@ -28,20 +31,39 @@ func (h HmacAlgo) String() string {
 		return "SHA1"
 	case HmacSha256:
 		return "SHA256"
+	case HmacSha512:
+		return "SHA512"
 	}
 	return "ERROR"
 }
 
-// Struct Totp holds the parameters needed to compute a TOTP code
+// MinDigits and MaxDigits are the supported range of code lengths. RFC4226
+// §5.3 says codes must be at least 6 digits. The dynamically truncated value
+// is a 31-bit integer, which tops out at 2147483647, so 10 digits is as long
+// as a code can get without padding it with meaningless leading zeros.
+const (
+	MinDigits int = 6
+	MaxDigits int = 10
+)
+
+// MaxPeriod is the longest supported TOTP period in seconds. RFC6238 allows
+// any positive time step, but much past an hour, the codes stop being very
+// "time-based" in any useful sense. This also keeps the ShowTotp countdown
+// from getting silly.
+const MaxPeriod int = 3600
+
+// Struct Totp holds the parameters needed to compute a TOTP code. Callers
+// should use Secret.Wipe() when they're done with it.
 type Totp struct {
-	Secret    []byte
+	Secret    SecretBuf
 	Digits    int
 	Algorithm HmacAlgo
 	Period    int
 }
 
 // NewTotp attempts to create a Totp instance with the requested parameters.
-// If the parameters fail the validation checks, NewTotp returns an error.
+// If the parameters fail the validation checks, NewTotp returns a
+// *ValidationError listing the problem with each bad parameter.
 // In case of unspecified parameters, defaults are:
 //   - digits = 6
 //   - algorithm = SHA1
@@ -51,35 +73,85 @@ type Totp struct {
 // https://github.com/google/google-authenticator/wiki/Key-Uri-Format
 func NewTotp(secret, digits, algorithm, period string) (*Totp, error) {
 	t := Totp{}
-	msg := ""
+	v := ValidationError{}
+	var e *FieldError
 	// Validate digits=...
-	switch digits {
-	case "", "6":
-		t.Digits = 6
-	case "8":
-		t.Digits = 8
-	default:
-		msg += " Digits should be empty, \"6\", or \"8\"."
-	}
+	t.Digits, e = parseDigits(digits)
+	v.add(e)
 	// Validate algorithm=...
+	t.Algorithm, e = parseAlgorithm(algorithm)
+	v.add(e)
+	// Validate period=...
+	t.Period, e = parsePeriod(period)
+	v.add(e)
+	// Validate base32 secret
+	t.Secret, e = parseSecret(secret)
+	v.add(e)
+	// Bail out with an error if any of the validation checks failed
+	if err := v.result(); err != nil {
+		t.Secret.Wipe()
+		return nil, err
+	}
+	// Yay, all good...
+	return &t, nil
+}
+
+// parseDigits validates a digits=... value. For unsupported values, the
+// returned FieldError describes the problem. Otherwise, it is nil.
+func parseDigits(digits string) (n int, e *FieldError) {
+	if digits == "" {
+		n = 6
+		return
+	}
+	n, err := strconv.Atoi(digits)
+	if err != nil || n < MinDigits || n > MaxDigits {
+		n = 0
+		e = &FieldError{"digits", digits, fmt.Sprintf(
+			"Digits should be empty or an integer in the range %v..%v.",
+			MinDigits, MaxDigits)}
+	}
+	return
+}
+
+// parsePeriod validates a period=... value. For unsupported values, the
+// returned FieldError describes the problem. Otherwise, it is nil.
+func parsePeriod(period string) (n int, e *FieldError) {
+	if period == "" {
+		n = 30
+		return
+	}
+	n, err := strconv.Atoi(period)
+	if err != nil || n < 1 || n > MaxPeriod {
+		n = 0
+		e = &FieldError{"period", period, fmt.Sprintf(
+			"Period should be empty or an integer in the range 1..%v.",
+			MaxPeriod)}
+	}
+	return
+}
+
+// parseAlgorithm validates an algorithm=... value. For unsupported values, the
+// returned FieldError describes the problem. Otherwise, it is nil.
+func parseAlgorithm(algorithm string) (h HmacAlgo, e *FieldError) {
 	switch algorithm {
 	case "", "SHA1":
-		t.Algorithm = HmacSha1
+		h = HmacSha1
 	case "SHA256":
-		t.Algorithm = HmacSha256
+		h = HmacSha256
+	case "SHA512":
+		h = HmacSha512
 	default:
-		msg += " Algorithm should be empty, \"SHA1\", or \"SHA256\"."
+		e = &FieldError{"algorithm", algorithm,
+			"Algorithm should be empty, \"SHA1\", \"SHA256\", or \"SHA512\"."}
 	}
-	// Validate period=...
-	switch period {
-	case "", "30":
-		t.Period = 30
-	case "60":
-		t.Period = 60
-	default:
-		msg += " Period should be empty, \"30\", or \"60\"."
-	}
-	// Validate base32 secret
+	return
+}
+
+// parseSecret decodes a base32 secret=... value. For secrets that won't
+// decode, the returned FieldError describes the problem. Otherwise, it is nil.
+// Error messages get shown on screen, so they never include the secret, and
+// the FieldError's Value is masked (see maskSecret).
+func parseSecret(secret string) (key SecretBuf, e *FieldError) {
 	// Secrets ideally shouldn't end with "=", and they really shouldn't end
 	// with a "%3D" url-escaped "=". But, I've seen authenticator app bug
 	// reports about TOTP QR Code URI parsing failures for secrets that do end
@@ -87,11 +159,11 @@ func NewTotp(secret, digits, algorithm, period string) (*Totp, error) {
 	// See previously mentioned documentation wiki page and RFC3548 §2.2:
 	//  https://datatracker.ietf.org/doc/html/rfc3548#section-2.2
 	if unescapedSecret, err := url.QueryUnescape(secret); err != nil {
-		msg += fmt.Sprintf(
-			" Secret value is weird (query unescape failed: \"%v\", %v).",
-			secret, err.Error())
+		// The url.EscapeError would quote part of the secret, so leave it out
+		e = &FieldError{"secret", maskSecret(secret),
+			"Secret value is weird (query unescape failed)."}
 	} else if secret == "" {
-		msg += " Secret value is blank."
+		e = &FieldError{"secret", secret, "Secret value is blank."}
 	} else {
 		// The wiki URI docs say the "=" suffix padding is not needed, but Go's
 		// base32 decoder seems to want padding for strings that are not an
@@ -106,19 +178,16 @@ func NewTotp(secret, digits, algorithm, period string) (*Totp, error) {
 		// Base32 decoder wants uppercase, but some TOTP QR Codes use lowercase
 		unescapedSecret := strings.ToUpper(unescapedSecret)
 		// Now decode the padded base32
-		t.Secret, err = base32.StdEncoding.DecodeString(unescapedSecret)
+		key, err = base32.StdEncoding.DecodeString(unescapedSecret)
 		if err != nil {
-			msg += fmt.Sprintf(
-				" Secret value is weird (base32 decode failed: \"%v\", %v).",
-				unescapedSecret, err.Error())
+			// Decoding stops at the bad byte, so wipe whatever came before it
+			key.Wipe()
+			key = nil
+			e = &FieldError{"secret", maskSecret(secret), fmt.Sprintf(
+				"Secret value is weird (base32 decode failed: %v).", err)}
 		}
 	}
-	// Bail out with an error if any of the validation checks failed
-	if msg != "" {
-		return nil, errors.New(msg)
-	}
-	// Yay, all good...
-	return &t, nil
+	return
 }
 
 // TotpCode returns a string with the TOTP code for the given Unix timestamp.
@@ -126,25 +195,6 @@ func NewTotp(secret, digits, algorithm, period string) (*Totp, error) {
 // text vectors from RFC6238 Appendix B.
 func (t Totp) CodeAtTime(unixTime int64) (
 	code string, validSeconds int, err error) {
-	// Because Go doesn't support proper enum types, this switch combines type
-	// validation with setting up the HMAC hashers using crypto/hmac,
-	// crypto/sha1, and crypto/sha256. The related docs are a little thin, but
-	// you can read them here:
-	//  - https://pkg.go.dev/crypto/hmac
-	//  - https://pkg.go.dev/hash#Hash
-	//  - https://pkg.go.dev/crypto/sha1@go1.21.1
-	//  - https://pkg.go.dev/crypto/sha256@go1.21.1
-	var h hash.Hash
-	switch t.Algorithm {
-	case HmacSha1:
-		h = hmac.New(sha1.New, t.Secret)
-	case HmacSha256:
-		h = hmac.New(sha256.New, t.Secret)
-	default:
-		err = errors.New("Unsupported algorithm value")
-		return
-	}
-
 	// Notes from RFC6238 (TOTP):
 	//  - Summarizing §4.1 and §4.2: The time (T) to be fed into the HMAC
 	//    hasher is calculated as:
@@ -158,47 +208,111 @@ func (t Totp) CodeAtTime(unixTime int64) (
 	//    sure why they took a detour through the whole hex string thing
 	//    instead of just spelling out clearly that you should feed the time
 	//    into the hash as big-endian bytes from an int64. Oh well.
-	//  - Appendix A: Converting HMAC output bytes to a decimal code selects a
-	//    31 bit integer from the output according to a window offset that is
-	//    calculated from the high byte of the HMAC output:
-	//     > int offset = hash[hash.length - 1] & 0xf;
-	//     > int binary =
-	//     >     ((hash[offset] & 0x7f) << 24) |
-	//     >     ((hash[offset + 1] & 0xff) << 16) |
-	//     >     ((hash[offset + 2] & 0xff) << 8) |
-	//     >     (hash[offset + 3] & 0xff);
-	//   - RFC4226 (HOTP) §5.3 and §5.4 give a longer explanation of the HMAC
-	//     and "dynamic truncation" algorithm. RFC6238 (TOTP) seems to assume
-	//     you already know how that stuff worked from reading about HOTP.
+	//  - The rest of the algorithm is HOTP with T as the counter. See the
+	//    notes for hotpCode().
 	//
 
-	// Floor the Unix timestamp with the 30 or 60 second period ("time-step").
-	// The RFC calls it time-step. The Google Authenticator QR code URI format
-	// cals it period. Whatever. I'll call it both.
-	var timeStep int64
-	switch t.Period {
-	case 30, 60:
-		timeStep = int64(t.Period)
-	default:
+	// Floor the Unix timestamp with the period ("time-step"). The RFC calls
+	// it time-step. The Google Authenticator QR code URI format cals it
+	// period. Whatever. I'll call it both.
+	if t.Period < 1 || t.Period > MaxPeriod {
 		err = errors.New("Unsupported period value")
 		return
 	}
+	timeStep := int64(t.Period)
 	floorTime := unixTime / timeStep
 	validSeconds = int(timeStep - (unixTime % timeStep))
 
 	// HMAC hash the floored timestamp as a big-endian int64. Do not be fooled
 	// by the hex timestamp stuff in the RFC6238 sample code. You're not
 	// supposed to hash the hex strings. Big-endian int64 is the way.
-	binary.Write(h, binary.BigEndian, floorTime)
+	code, err = hotpCode(t.Secret, t.Algorithm, t.Digits, uint64(floorTime))
+	return
+}
+
+// VerifyAtTime checks code against the TOTP codes for the time steps within
+// ±skew steps of the given Unix timestamp. If code matches, offset reports the
+// step it matched, relative to the step containing unixTime (e.g. -1 for the
+// previous code, 0 for the current code, or +1 for the next code). RFC6238
+// §5.2 recommends allowing one step of skew to cover network delay and clock
+// drift between the token and the validator.
+func (t Totp) VerifyAtTime(code string, unixTime int64, skew int) (
+	offset int, ok bool, err error) {
+	if skew < 0 {
+		err = errors.New("Skew should not be negative")
+		return
+	}
+	// Check every step in the window, even after finding a match, and use
+	// constant-time comparison so the time taken doesn't leak which step (or
+	// which digits) matched.
+	for i := -skew; i <= skew; i++ {
+		var c string
+		c, _, err = t.CodeAtTime(unixTime + int64(i*t.Period))
+		if err != nil {
+			return 0, false, err
+		}
+		if subtle.ConstantTimeCompare([]byte(c), []byte(code)) == 1 && !ok {
+			offset = i
+			ok = true
+		}
+	}
+	return
+}
+
+// hotpCode does the HMAC and dynamic truncation part of RFC4226 (HOTP) for
+// the given counter value. TOTP codes come from this too, with the floored
+// timestamp used as the counter.
+func hotpCode(secret []byte, algorithm HmacAlgo, digits int, counter uint64) (
+	code string, err error) {
+	// Because Go doesn't support proper enum types, this switch combines type
+	// validation with setting up the HMAC hashers using crypto/hmac,
+	// crypto/sha1, crypto/sha256, and crypto/sha512. The related docs are a
+	// little thin, but you can read them here:
+	//  - https://pkg.go.dev/crypto/hmac
+	//  - https://pkg.go.dev/hash#Hash
+	//  - https://pkg.go.dev/crypto/sha1@go1.21.1
+	//  - https://pkg.go.dev/crypto/sha256@go1.21.1
+	//  - https://pkg.go.dev/crypto/sha512@go1.21.1
+	var h hash.Hash
+	switch algorithm {
+	case HmacSha1:
+		h = hmac.New(sha1.New, secret)
+	case HmacSha256:
+		h = hmac.New(sha256.New, secret)
+	case HmacSha512:
+		h = hmac.New(sha512.New, secret)
+	default:
+		err = errors.New("Unsupported algorithm value")
+		return
+	}
+
+	// Notes from RFC4226 (HOTP) and RFC6238 (TOTP):
+	//  - RFC4226 §5.1: The counter is an 8-byte value that gets fed into the
+	//    HMAC hash in big-endian byte order.
+	//  - RFC6238 Appendix A: Converting HMAC output bytes to a decimal code
+	//    selects a 31 bit integer from the output according to a window
+	//    offset that is calculated from the high byte of the HMAC output:
+	//     > int offset = hash[hash.length - 1] & 0xf;
+	//     > int binary =
+	//     >     ((hash[offset] & 0x7f) << 24) |
+	//     >     ((hash[offset + 1] & 0xff) << 16) |
+	//     >     ((hash[offset + 2] & 0xff) << 8) |
+	//     >     (hash[offset + 3] & 0xff);
+	//   - RFC4226 (HOTP) §5.3 and §5.4 give a longer explanation of the HMAC
+	//     and "dynamic truncation" algorithm. RFC6238 (TOTP) seems to assume
+	//     you already know how that stuff worked from reading about HOTP.
+	//
+	binary.Write(h, binary.BigEndian, counter)
 	hmacOut := h.Sum(nil)
 
 	// Do the dynamic truncation thing from the RFC
 	offset := int(hmacOut[len(hmacOut)-1]) & 0xf
 	// Be paranoid and redundantly assert that the offset window falls inside
-	// the HMAC buffer's length. The algorithm should be SHA1 or SHA256. SHA1
-	// should output 20 bytes and SHA256 should output 32 bytes, offset should
-	// be in range 0..15, and 15+3=18 fits in 20 and 32. But, that's a lot of
-	// should. Perhaps a bug invalidated one of those assumed truths, so check.
+	// the HMAC buffer's length. The algorithm should be SHA1, SHA256, or
+	// SHA512. SHA1 should output 20 bytes, SHA256 should output 32 bytes,
+	// and SHA512 should output 64 bytes. Offset should be in range 0..15,
+	// and 15+3=18 fits in 20, 32, and 64. But, that's a lot of should.
+	// Perhaps a bug invalidated one of those assumed truths, so check.
 	if offset < 0 || offset >= len(hmacOut) || 0xf+3 >= len(hmacOut) {
 		err = errors.New("HMAC output buffer selection window OOR")
 		return
@@ -208,22 +322,18 @@ func (t Totp) CodeAtTime(unixTime int64) (
 	n |= int64(hmacOut[offset+1]&0xff) << 16
 	n |= int64(hmacOut[offset+2]&0xff) << 8
 	n |= int64(hmacOut[offset+3] & 0xff)
-	// Do % (10^digits) so the final code is the right number of digits
-	switch t.Digits {
-	case 6:
-		n %= 1000000
-		code = fmt.Sprintf("%06d", n)
-	case 8:
-		n %= 100000000
-		code = fmt.Sprintf("%08d", n)
-	default:
+	// Do % (10^digits) so the final code is the right number of digits. For
+	// 10 digits, the modulus is bigger than any 31-bit value, so the leading
+	// digit can only be 0, 1, or 2.
+	if digits < MinDigits || digits > MaxDigits {
 		err = errors.New("Unsupported digits value")
 		return
 	}
+	modulus := int64(1)
+	for i := 0; i < digits; i++ {
+		modulus *= 10
+	}
+	n %= modulus
+	code = fmt.Sprintf("%0*d", digits, n)
 	return
 }
-
-// TotpCode returns a string with the TOTP code for the current Unix time
-func (t Totp) CurrentCode() (string, int, error) {
-	return t.CodeAtTime(time.Now().Unix())
-}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crypto/hmac"
 	"crypto/sha1"
+	"errors"
 	"strings"
 	"testing"
 )
@@ -15,29 +16,37 @@ type TestVector struct {
 	Mode string
 }
 
-// TestVectors holds RFC6238 Appendix B SHA1 and SHA256 test vectors. I'm
-// ignoring SHA512 because it is not commonly supported. Appendix B states that
-// time step is 30 seconds and shared secret is ASCII "12345678901234567890".
-// But, the code in Appendix B uses an extended (repeats first 12 bytes)
-// version of that key. So, I'm not sure yet about the keys.
+// TestVectors holds RFC6238 Appendix B SHA1, SHA256, and SHA512 test vectors.
+// Appendix B states that time step is 30 seconds and shared secret is ASCII
+// "12345678901234567890". But, the code in Appendix B uses extended versions
+// of that key (repeated out to the hash's output size: 20 bytes for SHA1, 32
+// bytes for SHA256, and 64 bytes for SHA512). See test_vector_keys.py.
 var TestVectors []TestVector = []TestVector{
 	// Time (sec), Value of T (hex), TOTP, Mode
 	{59, "0000000000000001", "94287082", "SHA1"},
 	{59, "0000000000000001", "46119246", "SHA256"},
+	{59, "0000000000000001", "90693936", "SHA512"},
 	{1111111109, "00000000023523EC", "07081804", "SHA1"},
 	{1111111109, "00000000023523EC", "68084774", "SHA256"},
+	{1111111109, "00000000023523EC", "25091201", "SHA512"},
 	{1111111111, "00000000023523ED", "14050471", "SHA1"},
 	{1111111111, "00000000023523ED", "67062674", "SHA256"},
+	{1111111111, "00000000023523ED", "99943326", "SHA512"},
 	{1234567890, "000000000273EF07", "89005924", "SHA1"},
 	{1234567890, "000000000273EF07", "91819424", "SHA256"},
+	{1234567890, "000000000273EF07", "93441116", "SHA512"},
 	{2000000000, "0000000003F940AA", "69279037", "SHA1"},
 	{2000000000, "0000000003F940AA", "90698825", "SHA256"},
+	{2000000000, "0000000003F940AA", "38618901", "SHA512"},
 	{20000000000, "0000000027BC86AA", "65353130", "SHA1"},
 	{20000000000, "0000000027BC86AA", "77737706", "SHA256"},
+	{20000000000, "0000000027BC86AA", "47863826", "SHA512"},
 }
 
 var key1 string = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
 var key256 string = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA===="
+var key512 string = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" +
+	"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNA="
 
 func Test_RFC6238_Appendix_B_test_vectors(t *testing.T) {
 	for i, v := range TestVectors {
@@ -47,6 +56,8 @@ func Test_RFC6238_Appendix_B_test_vectors(t *testing.T) {
 			secret = key1
 		case "SHA256":
 			secret = key256
+		case "SHA512":
+			secret = key512
 		default:
 			t.Error("Unsupported mode:", v.Mode, "i:", i)
 		}
@@ -115,11 +126,12 @@ func Test_blank_secret(t *testing.T) {
 // Attempting to use an unsupported period should fail
 func Test_unsupported_period(t *testing.T) {
 	secret := "JBSWY3DPEHPK3PXP"
-	period := "29"
-	wantError := "Period should be empty, \"30\", or \"60\"."
-	_, err := NewTotp(secret, "", "", period)
-	if err != nil && !strings.Contains(err.Error(), wantError) {
-		t.Error("\nwanted:", wantError, "\ngot:", err.Error())
+	wantError := "Period should be empty or an integer in the range 1..3600."
+	for _, period := range []string{"0", "-30", "3601", "30s", "x"} {
+		_, err := NewTotp(secret, "", "", period)
+		if err == nil || !strings.Contains(err.Error(), wantError) {
+			t.Error("\ntried:", period, "\nwanted:", wantError, "\ngot:", err)
+		}
 	}
 }
 
@@ -127,7 +139,7 @@ func Test_unsupported_period(t *testing.T) {
 func Test_unsupported_algorithm(t *testing.T) {
 	secret := "JBSWY3DPEHPK3PXP"
 	algorithm := "MD5"
-	wantError := "Algorithm should be empty, \"SHA1\", or \"SHA256\"."
+	wantError := "Algorithm should be empty, \"SHA1\", \"SHA256\", or \"SHA512\"."
 	_, err := NewTotp(secret, "", algorithm, "")
 	if err != nil && !strings.Contains(err.Error(), wantError) {
 		t.Error("\nwanted:", wantError, "\ngot:", err.Error())
@@ -137,18 +149,59 @@ func Test_unsupported_algorithm(t *testing.T) {
 // Attempting to use an unsupported digits value should fail
 func Test_unsupported_digits(t *testing.T) {
 	secret := "JBSWY3DPEHPK3PXP"
-	digits := "7"
-	wantError := "Digits should be empty, \"6\", or \"8\"."
-	_, err := NewTotp(secret, digits, "", "")
-	if err != nil && !strings.Contains(err.Error(), wantError) {
-		t.Error("\nwanted:", wantError, "\ngot:", err.Error())
+	wantError := "Digits should be empty or an integer in the range 6..10."
+	for _, digits := range []string{"0", "5", "11", "-6", "x"} {
+		_, err := NewTotp(secret, digits, "", "")
+		if err == nil || !strings.Contains(err.Error(), wantError) {
+			t.Error("\ntried:", digits, "\nwanted:", wantError, "\ngot:", err)
+		}
+	}
+}
+
+// Validation errors should list each bad field, and work with errors.As
+func Test_validation_error_fields(t *testing.T) {
+	_, err := NewTotp("", "5", "MD5", "0")
+	var v *ValidationError
+	if !errors.As(err, &v) {
+		t.Fatal("\nwanted: *ValidationError\ngot:", err)
+	}
+	wantFields := []string{"digits", "algorithm", "period", "secret"}
+	if len(v.Problems) != len(wantFields) {
+		t.Fatal("\nwanted:", wantFields, "\ngot:", v.Problems)
+	}
+	for i, field := range wantFields {
+		if v.Problems[i].Field != field {
+			t.Error("\nwanted:", field, "\ngot:", v.Problems[i].Field)
+		}
+	}
+	if p := v.Field("algorithm"); p == nil || p.Value != "MD5" {
+		t.Error("\nwanted: algorithm problem with value MD5\ngot:", p)
+	}
+	// errors.As with *FieldError finds the first problem
+	var f *FieldError
+	if !errors.As(err, &f) || f.Field != "digits" {
+		t.Error("\nwanted: digits *FieldError\ngot:", f)
+	}
+	// The combined message keeps the old format
+	want := " Digits should be empty or an integer in the range 6..10." +
+		" Algorithm should be empty, \"SHA1\", \"SHA256\", or \"SHA512\"." +
+		" Period should be empty or an integer in the range 1..3600." +
+		" Secret value is blank."
+	if err.Error() != want {
+		t.Error("\nwanted:", want, "\ngot:", err.Error())
+	}
+	// A good secret shouldn't show up in the problem list
+	_, err = NewTotp("JBSWY3DPEHPK3PXP", "", "", "x")
+	if !errors.As(err, &v) || v.Field("secret") != nil ||
+		v.Field("period") == nil {
+		t.Error("\nwanted: only a period problem\ngot:", err)
 	}
 }
 
 // Attempting to use supported digits values should work
 func Test_supported_digits(t *testing.T) {
 	secret := "JBSWY3DPEHPK3PXP"
-	for _, digits := range []string{"6", "8"} {
+	for _, digits := range []string{"6", "7", "8", "9", "10"} {
 		_, err := NewTotp(secret, digits, "", "")
 		if err != nil {
 			t.Error("\ntried:", digits, "\ngot:", err.Error())
@@ -159,7 +212,7 @@ func Test_supported_digits(t *testing.T) {
 // Attempting to use supported algorithms should work
 func Test_supported_algorithms(t *testing.T) {
 	secret := "JBSWY3DPEHPK3PXP"
-	for _, algorithm := range []string{"SHA1", "SHA256"} {
+	for _, algorithm := range []string{"SHA1", "SHA256", "SHA512"} {
 		_, err := NewTotp(secret, "", algorithm, "")
 		if err != nil {
 			t.Error("\ntried:", algorithm, "\ngot:", err.Error())
@@ -170,7 +223,7 @@ func Test_supported_algorithms(t *testing.T) {
 // Attempting to use supported periods should work
 func Test_supported_periods(t *testing.T) {
 	secret := "JBSWY3DPEHPK3PXP"
-	for _, period := range []string{"30", "60"} {
+	for _, period := range []string{"1", "15", "20", "30", "60", "90", "3600"} {
 		_, err := NewTotp(secret, "", "", period)
 		if err != nil {
 			t.Error("\ntried:", period, "\ngot:", err.Error())
@@ -227,3 +280,85 @@ func Test_weird_but_valid_secrets(t *testing.T) {
 		}
 	}
 }
+
+// Non-30 second periods should floor the timestamp and count down properly
+func Test_period_countdown(t *testing.T) {
+	// With a 15 second period, t=1111111109 is 14 seconds into step 74074073
+	// and t=1111111111 is 1 second into step 74074074. With a 90 second
+	// period, t=1111111020 and t=1111111109 are the first and last seconds of
+	// step 12345678.
+	cases := []struct {
+		Period    string
+		Time      int64
+		WantValid int
+	}{
+		{"15", 1111111109, 1},
+		{"15", 1111111111, 14},
+		{"90", 1111111020, 90},
+		{"90", 1111111109, 1},
+	}
+	codes := map[string]string{}
+	for i, v := range cases {
+		totp, err := NewTotp(key1, "8", "SHA1", v.Period)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, validSeconds, err := totp.CodeAtTime(v.Time)
+		if err != nil {
+			t.Error(err)
+		}
+		if validSeconds != v.WantValid {
+			t.Error("\ni:", i, "\nwanted:", v.WantValid, "\ngot:", validSeconds)
+		}
+		if prev, ok := codes[v.Period]; ok {
+			sameStep := v.Period == "90"
+			if (prev == code) != sameStep {
+				t.Error("\ni:", i, "\nperiod:", v.Period, "\ncodes:", prev, code)
+			}
+		}
+		codes[v.Period] = code
+	}
+	// Period 30 at t=59 should match the RFC6238 vector with T=1
+	totp, _ := NewTotp(key1, "8", "SHA1", "")
+	if code, _, _ := totp.CodeAtTime(59); code != "94287082" {
+		t.Error("\nwanted: 94287082 \ngot:", code)
+	}
+}
+
+// Codes from nearby steps should verify with the right offset
+func Test_verify_skew_window(t *testing.T) {
+	totp, err := NewTotp(key1, "8", "SHA1", "30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// RFC6238 vectors at t=1111111109 and t=1111111111 are adjacent steps
+	cases := []struct {
+		Code       string
+		Time       int64
+		Skew       int
+		WantOffset int
+		WantOk     bool
+	}{
+		{"07081804", 1111111109, 0, 0, true},
+		{"14050471", 1111111109, 0, 0, false},
+		{"14050471", 1111111109, 1, 1, true},
+		{"07081804", 1111111111, 1, -1, true},
+		{"07081804", 1111111111 + 30, 1, 0, false},
+		{"07081804", 1111111111 + 30, 2, -2, true},
+		{"89005924", 1111111109, 3, 0, false},
+		{"", 1111111109, 1, 0, false},
+	}
+	for i, v := range cases {
+		offset, ok, err := totp.VerifyAtTime(v.Code, v.Time, v.Skew)
+		if err != nil {
+			t.Error(err)
+		}
+		if ok != v.WantOk || offset != v.WantOffset {
+			t.Error("\ni:", i, "\nwanted:", v.WantOffset, v.WantOk,
+				"\ngot:", offset, ok)
+		}
+	}
+	if _, _, err := totp.VerifyAtTime("07081804", 1111111109, -1); err == nil {
+		t.Error("\nwanted: error for negative skew \ngot: nil")
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import "strings"
+
+// FieldError describes a problem with one parameter passed to NewTotp or
+// NewHotp. Field is the name of the parameter as it appears in a QR Code URI
+// query (e.g. "secret" or "digits"), and Value is the value that failed (masked,
+// for secrets).
+type FieldError struct {
+	Field   string
+	Value   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return e.Message
+}
+
+// ValidationError is returned by NewTotp and NewHotp when any parameters fail
+// their validation checks. It lists the problems in the order the parameters
+// were checked. To check for a problem with a particular field, use Field, or
+// use errors.As with a *FieldError to get the first problem.
+type ValidationError struct {
+	Problems []*FieldError
+}
+
+// Error combines the messages for all the problems into one string, with a
+// space before each sentence.
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	for _, p := range e.Problems {
+		b.WriteString(" ")
+		b.WriteString(p.Message)
+	}
+	return b.String()
+}
+
+// Unwrap lets errors.As and errors.Is see the individual field problems
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Problems))
+	for i, p := range e.Problems {
+		errs[i] = p
+	}
+	return errs
+}
+
+// Field returns the problem for the named field, or nil if that field passed
+// validation.
+func (e *ValidationError) Field(name string) *FieldError {
+	for _, p := range e.Problems {
+		if p.Field == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// add appends a problem if it isn't nil
+func (e *ValidationError) add(p *FieldError) {
+	if p != nil {
+		e.Problems = append(e.Problems, p)
+	}
+}
+
+// result returns e as an error, or nil if there were no problems. This avoids
+// the classic Go gotcha of returning a nil *ValidationError as a non-nil error.
+func (e *ValidationError) result() error {
+	if len(e.Problems) == 0 {
+		return nil
+	}
+	return e
+}
@@ -0,0 +1,149 @@
+package vault
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+)
+
+// Scrypt key derivation from RFC7914. The Go standard library doesn't have
+// scrypt (it lives in golang.org/x/crypto), and avoiding dependencies keeps
+// the audit surface small, so this is a direct transcription of the RFC's
+// pseudocode. It's short because the heavy lifting is done by PBKDF2, which
+// is a few lines of crypto/hmac, and the Salsa20/8 core.
+
+// pbkdf2Sha256 is PBKDF2-HMAC-SHA256 from RFC8018 §5.2
+func pbkdf2Sha256(password, salt []byte, iter, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	var dk []byte
+	var block [4]byte
+	for i := uint32(1); len(dk) < keyLen; i++ {
+		// U_1 = PRF(P, S || INT(i))
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(block[:], i)
+		prf.Write(block[:])
+		u := prf.Sum(nil)
+		t := append([]byte{}, u...)
+		// U_c = PRF(P, U_{c-1}), T_i = U_1 xor U_2 xor ... xor U_c
+		for c := 1; c < iter; c++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		dk = append(dk, t...)
+	}
+	return dk[:keyLen]
+}
+
+// salsa208 applies the Salsa20/8 core (RFC7914 §3) to b in place
+func salsa208(b *[16]uint32) {
+	x := *b
+	for i := 0; i < 8; i += 2 {
+		// Odd round (columns)
+		x[4] ^= bits.RotateLeft32(x[0]+x[12], 7)
+		x[8] ^= bits.RotateLeft32(x[4]+x[0], 9)
+		x[12] ^= bits.RotateLeft32(x[8]+x[4], 13)
+		x[0] ^= bits.RotateLeft32(x[12]+x[8], 18)
+		x[9] ^= bits.RotateLeft32(x[5]+x[1], 7)
+		x[13] ^= bits.RotateLeft32(x[9]+x[5], 9)
+		x[1] ^= bits.RotateLeft32(x[13]+x[9], 13)
+		x[5] ^= bits.RotateLeft32(x[1]+x[13], 18)
+		x[14] ^= bits.RotateLeft32(x[10]+x[6], 7)
+		x[2] ^= bits.RotateLeft32(x[14]+x[10], 9)
+		x[6] ^= bits.RotateLeft32(x[2]+x[14], 13)
+		x[10] ^= bits.RotateLeft32(x[6]+x[2], 18)
+		x[3] ^= bits.RotateLeft32(x[15]+x[11], 7)
+		x[7] ^= bits.RotateLeft32(x[3]+x[15], 9)
+		x[11] ^= bits.RotateLeft32(x[7]+x[3], 13)
+		x[15] ^= bits.RotateLeft32(x[11]+x[7], 18)
+		// Even round (rows)
+		x[1] ^= bits.RotateLeft32(x[0]+x[3], 7)
+		x[2] ^= bits.RotateLeft32(x[1]+x[0], 9)
+		x[3] ^= bits.RotateLeft32(x[2]+x[1], 13)
+		x[0] ^= bits.RotateLeft32(x[3]+x[2], 18)
+		x[6] ^= bits.RotateLeft32(x[5]+x[4], 7)
+		x[7] ^= bits.RotateLeft32(x[6]+x[5], 9)
+		x[4] ^= bits.RotateLeft32(x[7]+x[6], 13)
+		x[5] ^= bits.RotateLeft32(x[4]+x[7], 18)
+		x[11] ^= bits.RotateLeft32(x[10]+x[9], 7)
+		x[8] ^= bits.RotateLeft32(x[11]+x[10], 9)
+		x[9] ^= bits.RotateLeft32(x[8]+x[11], 13)
+		x[10] ^= bits.RotateLeft32(x[9]+x[8], 18)
+		x[12] ^= bits.RotateLeft32(x[15]+x[14], 7)
+		x[13] ^= bits.RotateLeft32(x[12]+x[15], 9)
+		x[14] ^= bits.RotateLeft32(x[13]+x[12], 13)
+		x[15] ^= bits.RotateLeft32(x[14]+x[13], 18)
+	}
+	for i := range b {
+		b[i] += x[i]
+	}
+}
+
+// blockMix is scryptBlockMix from RFC7914 §4. The input b is 2*r 64-byte
+// blocks as 16*2*r little-endian words, and the output goes in y.
+func blockMix(b, y []uint32, r int) {
+	var x [16]uint32
+	copy(x[:], b[(2*r-1)*16:])
+	for i := 0; i < 2*r; i++ {
+		for j := range x {
+			x[j] ^= b[i*16+j]
+		}
+		salsa208(&x)
+		// Even blocks go in the first half of y and odd blocks in the second
+		copy(y[(i/2+(i%2)*r)*16:], x[:])
+	}
+}
+
+// roMix is scryptROMix from RFC7914 §5, operating on b in place
+func roMix(b []uint32, r, n int) {
+	words := 32 * r
+	v := make([]uint32, words*n)
+	x := make([]uint32, words)
+	y := make([]uint32, words)
+	copy(x, b)
+	for i := 0; i < n; i++ {
+		copy(v[i*words:], x)
+		blockMix(x, y, r)
+		x, y = y, x
+	}
+	for i := 0; i < n; i++ {
+		// Integerify is the first word of the last 64-byte block. Since n is
+		// a power of 2 less than 2^32, the low word is all that matters.
+		j := int(x[(2*r-1)*16]) & (n - 1)
+		for k := range x {
+			x[k] ^= v[j*words+k]
+		}
+		blockMix(x, y, r)
+		x, y = y, x
+	}
+	copy(b, x)
+}
+
+// scrypt derives a keyLen byte key from password and salt using CPU/memory
+// cost N=2^logN, block size r, and parallelization p (RFC7914 §6).
+func scrypt(password, salt []byte, logN, r, p, keyLen int) ([]byte, error) {
+	if logN < 1 || logN > 30 || r < 1 || p < 1 || r*p >= 1<<30 {
+		return nil, errors.New("unsupported scrypt parameters")
+	}
+	n := 1 << logN
+	blockLen := 128 * r
+	b := pbkdf2Sha256(password, salt, 1, p*blockLen)
+	words := make([]uint32, 32*r)
+	for i := 0; i < p; i++ {
+		chunk := b[i*blockLen : (i+1)*blockLen]
+		for j := range words {
+			words[j] = binary.LittleEndian.Uint32(chunk[j*4:])
+		}
+		roMix(words, r, n)
+		for j, w := range words {
+			binary.LittleEndian.PutUint32(chunk[j*4:], w)
+		}
+	}
+	return pbkdf2Sha256(password, b, 1, keyLen), nil
+}
@@ -0,0 +1,128 @@
+/*
+Package vault encrypts data with a passphrase for storing on disk.
+
+Totp-util normally keeps everything in RAM. The vault is an opt-in exception
+for people who want to save a list of profiles between sessions. It uses only
+the standard library: scrypt (RFC7914, see scrypt.go) to stretch the
+passphrase into a key, and AES-256-GCM for authenticated encryption.
+
+A vault file is a header followed by the GCM ciphertext and tag:
+
+	offset  size  field
+	0       8     magic "TOTPVLT\n"
+	8       1     format version (currently 1)
+	9       1     scrypt log2(N)
+	10      1     scrypt r
+	11      1     scrypt p
+	12      16    scrypt salt
+	28      12    GCM nonce
+	40      ...   ciphertext + 16 byte GCM tag
+
+The whole header is used as GCM additional data, so tampering with the KDF
+parameters or version byte makes decryption fail rather than silently using
+weaker settings.
+*/
+package vault
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// Version is the vault format version written by Seal
+const Version byte = 1
+
+// Default scrypt parameters. N=2^15 and r=8 use 32 MiB and take a fraction
+// of a second, which matches the interactive login recommendation in RFC7914.
+const (
+	LogN byte = 15
+	R    byte = 8
+	P    byte = 1
+)
+
+const (
+	magic      = "TOTPVLT\n"
+	saltLen    = 16
+	nonceLen   = 12
+	headerLen  = len(magic) + 4 + saltLen + nonceLen
+	keyLen     = 32
+	maxLogN    = 20
+	maxRTimesP = 64
+)
+
+// ErrDecrypt means the passphrase was wrong or the file was damaged. GCM can't
+// tell the difference.
+var ErrDecrypt = errors.New("Unable to decrypt vault (wrong passphrase or damaged file)")
+
+// Seal encrypts plaintext with a key derived from passphrase using the default
+// scrypt parameters and a fresh random salt and nonce.
+func Seal(passphrase, plaintext []byte) ([]byte, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("Passphrase should not be empty")
+	}
+	header := make([]byte, headerLen)
+	copy(header, magic)
+	n := len(magic)
+	header[n], header[n+1], header[n+2], header[n+3] = Version, LogN, R, P
+	if _, err := rand.Read(header[n+4:]); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(passphrase, header)
+	if err != nil {
+		return nil, err
+	}
+	nonce := header[headerLen-nonceLen:]
+	return aead.Seal(header, nonce, plaintext, header), nil
+}
+
+// Open checks the header of a vault made by Seal, then decrypts it.
+func Open(passphrase, data []byte) ([]byte, error) {
+	if len(data) < headerLen || !bytes.HasPrefix(data, []byte(magic)) {
+		return nil, errors.New("Not a totp-util vault file")
+	}
+	if v := data[len(magic)]; v != Version {
+		return nil, fmt.Errorf("Unsupported vault version %v", v)
+	}
+	header := data[:headerLen]
+	aead, err := newAEAD(passphrase, header)
+	if err != nil {
+		return nil, err
+	}
+	nonce := header[headerLen-nonceLen:]
+	plaintext, err := aead.Open(nil, nonce, data[headerLen:], header)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// newAEAD derives the key using the scrypt parameters and salt from header.
+// The limits on the parameters keep a hostile file from asking for absurd
+// amounts of memory or time.
+func newAEAD(passphrase, header []byte) (cipher.AEAD, error) {
+	n := len(magic)
+	logN, r, p := int(header[n+1]), int(header[n+2]), int(header[n+3])
+	if logN > maxLogN || r*p > maxRTimesP {
+		return nil, fmt.Errorf(
+			"Vault scrypt parameters are too expensive (logN=%v r=%v p=%v)",
+			logN, r, p)
+	}
+	salt := header[n+4 : n+4+saltLen]
+	key, err := scrypt(passphrase, salt, logN, r, p, keyLen)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	// The cipher keeps its own expanded copy, so the key can go now
+	for i := range key {
+		key[i] = 0
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,96 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// PBKDF2-HMAC-SHA256 should match the test vectors in RFC7914 §11
+func TestPbkdf2Sha256RFC7914(t *testing.T) {
+	cases := []struct {
+		Password, Salt string
+		Iter           int
+		Want           string
+	}{
+		{"passwd", "salt", 1,
+			"55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+				"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"Password", "NaCl", 80000,
+			"4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56" +
+				"a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d"},
+	}
+	for _, c := range cases {
+		got := hex.EncodeToString(
+			pbkdf2Sha256([]byte(c.Password), []byte(c.Salt), c.Iter, 64))
+		if got != c.Want {
+			t.Error("\ntried:", c.Password, "\nwanted:", c.Want, "\ngot:", got)
+		}
+	}
+}
+
+// Scrypt should match the test vectors in RFC7914 §12
+func TestScryptRFC7914(t *testing.T) {
+	cases := []struct {
+		Password, Salt string
+		LogN, R, P     int
+		Want           string
+	}{
+		{"", "", 4, 1, 1,
+			"77d6576238657b203b19ca42c18a0497f16b4844e3074ae8dfdffa3fede21442" +
+				"fcd0069ded0948f8326a753a0fc81f17e8d3e0fb2e0d3628cf35e20c38d18906"},
+		{"password", "NaCl", 10, 8, 16,
+			"fdbabe1c9d3472007856e7190d01e9fe7c6ad7cbc8237830e77376634b373162" +
+				"2eaf30d92e22a3886ff109279d9830dac727afb94a83ee6d8360cbdfa2cc0640"},
+	}
+	for _, c := range cases {
+		key, err := scrypt([]byte(c.Password), []byte(c.Salt),
+			c.LogN, c.R, c.P, 64)
+		if got := hex.EncodeToString(key); err != nil || got != c.Want {
+			t.Error("\ntried:", c.Password, "\nwanted:", c.Want, "\ngot:", got,
+				err)
+		}
+	}
+}
+
+// Sealed data should open with the right passphrase and nothing else
+func TestSealOpen(t *testing.T) {
+	pass := []byte("correct horse battery staple")
+	plaintext := []byte(`[{"secret":"JBSWY3DPEHPK3PXP"}]`)
+	data, err := Seal(pass, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("JBSWY3DPEHPK3PXP")) {
+		t.Error("secret visible in sealed data")
+	}
+	if got, err := Open(pass, data); err != nil || !bytes.Equal(got, plaintext) {
+		t.Error("\nwanted:", string(plaintext), "\ngot:", string(got), err)
+	}
+	if _, err := Open([]byte("wrong"), data); err != ErrDecrypt {
+		t.Error("\nwrong passphrase\nwanted:", ErrDecrypt, "\ngot:", err)
+	}
+	// Flipping a bit in the salt should break the authentication tag
+	tampered := append([]byte{}, data...)
+	tampered[20] ^= 1
+	if _, err := Open(pass, tampered); err != ErrDecrypt {
+		t.Error("\ntampered salt\nwanted:", ErrDecrypt, "\ngot:", err)
+	}
+	// Unknown versions and expensive parameters get rejected before the KDF
+	tampered = append([]byte{}, data...)
+	tampered[8] = 2
+	if _, err := Open(pass, tampered); err == nil {
+		t.Error("\nbad version\nwanted: error\ngot: nil")
+	}
+	tampered = append([]byte{}, data...)
+	tampered[9] = 30
+	if _, err := Open(pass, tampered); err == nil {
+		t.Error("\nexpensive logN\nwanted: error\ngot: nil")
+	}
+	if _, err := Open(pass, []byte("not a vault")); err == nil {
+		t.Error("\nnot a vault\nwanted: error\ngot: nil")
+	}
+	if _, err := Seal(nil, plaintext); err == nil {
+		t.Error("\nempty passphrase\nwanted: error\ngot: nil")
+	}
+}